@@ -0,0 +1,215 @@
+package bspline
+
+import (
+	"fmt"
+
+	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+)
+
+// Add a + b as a B-Spline on [k_0, k_(count-1)], which both should share.
+// The lower order is elevated, and the knots of both are merged.
+// Periodic B-Splines can only be added to periodic B-Splines of the same order and period.
+func Add(a, b BSpline) (BSpline, error) {
+	return combine(a, b, 1)
+}
+
+// Sub a - b, see Add
+func Sub(a, b BSpline) (BSpline, error) {
+	return combine(a, b, -1)
+}
+
+// Scale s * a on the same knots
+func Scale(a BSpline, s float64) (BSpline, error) {
+	switch a := a.(type) {
+	case *bSplineSimple:
+		coefs := make([]float64, len(a.coefs))
+		for j, c := range a.coefs {
+			coefs[j] = s * c
+		}
+		return newBSplineSimple(a.order, a.knots, coefs), nil
+	case *bSplinePeriodic:
+		coefs := make([]float64, len(a.coefs))
+		for j, c := range a.coefs {
+			coefs[j] = s * c
+		}
+		return newBSplinePeriodic(a.spline.order, a.spline.knots, coefs), nil
+	}
+	return nil, fmt.Errorf("[BSpline] Unknown B-Spline %T: %w", a, splineerr.ErrInvalidArgument)
+}
+
+// Mul Exact product a * b, a B-Spline of order a.Order() + b.Order() on [k_0, k_(count-1)],
+// which both should share. A knot of multiplicity m in a, where a is C^(p-m), keeps
+// the product C^(p-m) with the multiplicity q+m, and likewise for b.
+// The coefficients are the least squares fit of the product, which is exact as it lies in the space.
+// Periodic B-Splines are not supported.
+func Mul(a, b BSpline) (BSpline, error) {
+	sa, ok := a.(*bSplineSimple)
+	sb, okb := b.(*bSplineSimple)
+	if !ok || !okb {
+		return nil, fmt.Errorf("[BSpline] Product of %T and %T is not supported: %w", a, b, splineerr.ErrInvalidArgument)
+	}
+	p, q := sa.order, sb.order
+	breaksA, multA := sa.breaks()
+	breaksB, multB := sb.breaks()
+	if breaksA[0] != breaksB[0] || breaksA[len(breaksA)-1] != breaksB[len(breaksB)-1] {
+		return nil, fmt.Errorf("[BSpline] Product on different intervals: %w", splineerr.ErrInvalidKnots)
+	}
+
+	order := p + q
+	breaks, mA, mB := mergeBreaks(breaksA, multA, breaksB, multB)
+	var sequence []float64
+	for i, u := range breaks {
+		var m int
+		if mA[i] > 0 {
+			m = q + mA[i]
+		}
+		if mB[i] > 0 && p+mB[i] > m {
+			m = p + mB[i]
+		}
+		if i == 0 || i == len(breaks)-1 || m > order+1 {
+			m = order + 1
+		}
+		for ; m > 0; m-- {
+			sequence = append(sequence, u)
+		}
+	}
+	knots, err := knot.NewSequenceKnot(order, sequence...)
+	if err != nil {
+		return nil, err
+	}
+	product := newBSplineSimple(order, knots, make([]float64, knots.Count()+order-1))
+	f := func(x float64) float64 {
+		return sa.At(x) * sb.At(x)
+	}
+	if err := project(f, order, product, len(product.coefs), breaks); err != nil {
+		return nil, err
+	}
+	return product, nil
+}
+
+// combine a + sign * b
+func combine(a, b BSpline, sign float64) (BSpline, error) {
+	if pa, ok := a.(*bSplinePeriodic); ok {
+		pb, ok := b.(*bSplinePeriodic)
+		if !ok || pa.spline.order != pb.spline.order || pa.period != pb.period || pa.spline.knots.At(0) != pb.spline.knots.At(0) {
+			return nil, fmt.Errorf("[BSpline] Periodic B-Splines of different orders or periods: %w", splineerr.ErrInvalidKnots)
+		}
+		ra, rb, err := commonPeriodicKnots(pa, pb)
+		if err != nil {
+			return nil, err
+		}
+		coefs := make([]float64, len(ra.coefs))
+		for j := range coefs {
+			coefs[j] = ra.coefs[j] + sign*rb.coefs[j]
+		}
+		return newBSplinePeriodic(ra.spline.order, ra.spline.knots, coefs), nil
+	}
+	if _, ok := b.(*bSplinePeriodic); ok {
+		return nil, fmt.Errorf("[BSpline] Periodic and non-periodic B-Splines: %w", splineerr.ErrInvalidKnots)
+	}
+
+	order := a.Order()
+	if b.Order() > order {
+		order = b.Order()
+	}
+	ea, err := a.Elevate(order - a.Order())
+	if err != nil {
+		return nil, err
+	}
+	eb, err := b.Elevate(order - b.Order())
+	if err != nil {
+		return nil, err
+	}
+	ra, rb, err := commonKnots(ea.(*bSplineSimple), eb.(*bSplineSimple))
+	if err != nil {
+		return nil, err
+	}
+	coefs := make([]float64, len(ra.coefs))
+	for j := range coefs {
+		coefs[j] = ra.coefs[j] + sign*rb.coefs[j]
+	}
+	return newBSplineSimple(order, ra.knots, coefs), nil
+}
+
+// commonKnots a and b refined to the same knots
+func commonKnots(a, b *bSplineSimple) (*bSplineSimple, *bSplineSimple, error) {
+	breaksA, multA := a.breaks()
+	breaksB, multB := b.breaks()
+	if breaksA[0] != breaksB[0] || breaksA[len(breaksA)-1] != breaksB[len(breaksB)-1] {
+		return nil, nil, fmt.Errorf("[BSpline] B-Splines on different intervals: %w", splineerr.ErrInvalidKnots)
+	}
+	breaks, mA, mB := mergeBreaks(breaksA, multA, breaksB, multB)
+	var insertA, insertB []float64
+	for i, u := range breaks {
+		for m := mA[i]; m < mB[i]; m++ {
+			insertA = append(insertA, u)
+		}
+		for m := mB[i]; m < mA[i]; m++ {
+			insertB = append(insertB, u)
+		}
+	}
+	ra, err := a.Refine(insertA)
+	if err != nil {
+		return nil, nil, err
+	}
+	rb, err := b.Refine(insertB)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ra.(*bSplineSimple), rb.(*bSplineSimple), nil
+}
+
+// commonPeriodicKnots a and b refined to the same periodic knots
+func commonPeriodicKnots(a, b *bSplinePeriodic) (*bSplinePeriodic, *bSplinePeriodic, error) {
+	contains := func(s *bSplinePeriodic, u float64) bool {
+		for i := 0; i < s.spline.knots.Count(); i++ {
+			if s.spline.knots.At(i) == u {
+				return true
+			}
+		}
+		return false
+	}
+	var insertA, insertB []float64
+	for i := 1; i < b.spline.knots.Count()-1; i++ {
+		if u := b.spline.knots.At(i); !contains(a, u) {
+			insertA = append(insertA, u)
+		}
+	}
+	for i := 1; i < a.spline.knots.Count()-1; i++ {
+		if u := a.spline.knots.At(i); !contains(b, u) {
+			insertB = append(insertB, u)
+		}
+	}
+	ra, err := a.Refine(insertA)
+	if err != nil {
+		return nil, nil, err
+	}
+	rb, err := b.Refine(insertB)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ra.(*bSplinePeriodic), rb.(*bSplinePeriodic), nil
+}
+
+// mergeBreaks Union of the sorted breaks, with the multiplicities of each, 0 if absent
+func mergeBreaks(breaksA []float64, multA []int, breaksB []float64, multB []int) ([]float64, []int, []int) {
+	var breaks []float64
+	var mA, mB []int
+	i, j := 0, 0
+	for i < len(breaksA) || j < len(breaksB) {
+		switch {
+		case j == len(breaksB) || (i < len(breaksA) && breaksA[i] < breaksB[j]):
+			breaks, mA, mB = append(breaks, breaksA[i]), append(mA, multA[i]), append(mB, 0)
+			i++
+		case i == len(breaksA) || breaksB[j] < breaksA[i]:
+			breaks, mA, mB = append(breaks, breaksB[j]), append(mA, 0), append(mB, multB[j])
+			j++
+		default:
+			breaks, mA, mB = append(breaks, breaksA[i]), append(mA, multA[i]), append(mB, multB[j])
+			i++
+			j++
+		}
+	}
+	return breaks, mA, mB
+}
@@ -0,0 +1,101 @@
+package bspline
+
+import "github.com/helloworldpark/gonaturalspline/ppoly"
+
+// BezierSegment Polynomial piece of a B-Spline on [Start, End] in the Bernstein form
+//     sum_k Control[k] * C(n, k) * s^k * (1 - s)^(n-k),  s = (x - Start) / (End - Start)
+// where n = len(Control) - 1 is the order.
+type BezierSegment struct {
+	Start, End float64
+	Control    []float64
+}
+
+// At de Casteljau's algorithm at x
+func (s BezierSegment) At(x float64) float64 {
+	t := (x - s.Start) / (s.End - s.Start)
+	d := append([]float64{}, s.Control...)
+	for r := 1; r < len(d); r++ {
+		for i := 0; i < len(d)-r; i++ {
+			d[i] = (1-t)*d[i] + t*d[i+1]
+		}
+	}
+	return d[0]
+}
+
+// taylor Coefficients of the segment in powers of x - Start, from the forward differences
+//     a_k = C(n, k) * (Delta^k Control)_0 / (End - Start)^k
+func (s BezierSegment) taylor() []float64 {
+	n := len(s.Control) - 1
+	h := s.End - s.Start
+	d := append([]float64{}, s.Control...)
+	a := make([]float64, n+1)
+	binomial, scale := 1.0, 1.0
+	for k := 0; k <= n; k++ {
+		a[k] = binomial * d[0] / scale
+		for i := 0; i < n-k; i++ {
+			d[i] = d[i+1] - d[i]
+		}
+		binomial = binomial * float64(n-k) / float64(k+1)
+		scale *= h
+	}
+	return a
+}
+
+// BezierSegments Bézier segments of b on the knot spans of [k_0, k_(count-1)], by blossoming
+//     Control[k] = B[f](a, ... , a, c, ... , c)
+// with order-k arguments a and k arguments c on the span [a, c].
+func (b *bSplineSimple) BezierSegments() []BezierSegment {
+	breaks, _ := b.breaks()
+	coef := func(j int) float64 {
+		if 0 <= j && j < len(b.coefs) {
+			return b.coefs[j]
+		}
+		return 0
+	}
+	u := make([]float64, b.order)
+	segments := make([]BezierSegment, 0, len(breaks)-1)
+	for i := 0; i+1 < len(breaks); i++ {
+		a, c := breaks[i], breaks[i+1]
+		mu, _ := b.span(a)
+		control := make([]float64, b.order+1)
+		for k := range control {
+			for r := range u {
+				u[r] = a
+				if r >= b.order-k {
+					u[r] = c
+				}
+			}
+			control[k] = blossom(b.order, b.knot, coef, mu, u)
+		}
+		segments = append(segments, BezierSegment{Start: a, End: c, Control: control})
+	}
+	return segments
+}
+
+// ToPiecewisePolynomial Polynomial pieces of b on the knot spans of [k_0, k_(count-1)]
+func (b *bSplineSimple) ToPiecewisePolynomial() *ppoly.PiecewisePolynomial {
+	return bezierToPiecewisePolynomial(b.BezierSegments())
+}
+
+// BezierSegments Bézier segments of b on the knot spans of one period, [k_0, k_(count-1)]
+func (b *bSplinePeriodic) BezierSegments() []BezierSegment {
+	return b.spline.BezierSegments()
+}
+
+// ToPiecewisePolynomial Polynomial pieces of b on the knot spans of one period, [k_0, k_(count-1)].
+// Wrap the abscissae into the period before evaluating them.
+func (b *bSplinePeriodic) ToPiecewisePolynomial() *ppoly.PiecewisePolynomial {
+	return b.spline.ToPiecewisePolynomial()
+}
+
+// bezierToPiecewisePolynomial Power form of consecutive segments
+func bezierToPiecewisePolynomial(segments []BezierSegment) *ppoly.PiecewisePolynomial {
+	breaks := make([]float64, 0, len(segments)+1)
+	coefs := make([][]float64, 0, len(segments))
+	for _, s := range segments {
+		breaks = append(breaks, s.Start)
+		coefs = append(coefs, s.taylor())
+	}
+	breaks = append(breaks, segments[len(segments)-1].End)
+	return &ppoly.PiecewisePolynomial{Breaks: breaks, Coefs: coefs}
+}
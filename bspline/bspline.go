@@ -1,13 +1,65 @@
 package bspline
 
-import "github.com/helloworldpark/gonaturalspline/knot"
+import (
+	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/ppoly"
+)
 
 // BSpline BSpline represents a function that implements B-Spline.
 type BSpline interface {
 	At(x float64) float64
+	// EvalInto dst[i] = At(xs[i]). Faster when xs is sorted in increasing order.
+	// Panics if len(dst) < len(xs).
+	EvalInto(dst, xs []float64)
 	Knots() knot.Knot
 	Order() int
 	SetCoef(idx int, v float64)
 	GetCoef(idx int) float64
 	GetBSpline(idx int) BSplineFunc
+	// NonzeroBSplines Values of the basis functions which may be nonzero at x,
+	// i.e. GetBSpline(first + i).Evaluate(x) = values[i]
+	NonzeroBSplines(x float64) (first int, values []float64)
+
+	// DerivativeAt k-th derivative at x
+	DerivativeAt(x float64, k int) float64
+	// Derivative k-th derivative as a B-Spline of order Order()-k on the same knots,
+	// equal to DerivativeAt on [k_0, k_(count-1)]
+	Derivative(k int) (BSpline, error)
+
+	// Integral Definite integral from a to b
+	Integral(a, b float64) float64
+	// Antiderivative B-Spline of order Order()+1 on the same knots whose derivative is this
+	// on [k_0, k_(count-1)]; differences of it equal Integral only for bounds up to k_(count-1)
+	Antiderivative() (BSpline, error)
+
+	// InsertKnot Equivalent B-Spline on the knots with x inserted times more
+	InsertKnot(x float64, times int) (BSpline, error)
+	// Refine Equivalent B-Spline on the knots with newKnots inserted
+	Refine(newKnots []float64) (BSpline, error)
+	// Simplify B-Spline with interior knots removed while deviating from this by at most tol,
+	// and the deviation
+	Simplify(tol float64) (BSpline, float64, error)
+
+	// Elevate B-Spline of order Order()+k equal to this on [k_0, k_(count-1)].
+	// Periodic B-Splines stay periodic only for k = 0, as their simple knots cannot keep the continuity;
+	// for k > 0 they are elevated over one period into a non-periodic B-Spline.
+	Elevate(k int) (BSpline, error)
+	// Reduce B-Spline of order Order()-k approximating this within tol, and the deviation
+	Reduce(k int, tol float64) (BSpline, float64, error)
+
+	// ToPiecewisePolynomial Polynomial pieces on the knot spans of [k_0, k_(count-1)]
+	ToPiecewisePolynomial() *ppoly.PiecewisePolynomial
+	// BezierSegments Polynomial pieces on the knot spans of [k_0, k_(count-1)] in the Bernstein form
+	BezierSegments() []BezierSegment
+	// Roots Abscissae in [k_0, k_(count-1)] where this equals level, in increasing order.
+	// Knot spans where this equals level throughout are skipped.
+	Roots(level float64) []float64
+	// Extrema Strict local minima and maxima in the interior of [k_0, k_(count-1)], in increasing order
+	Extrema() []ppoly.Extremum
+	// InflectionPoints Abscissae in the interior of [k_0, k_(count-1)] where the second derivative changes sign
+	InflectionPoints() []float64
+	// Min Smallest value on [from, to] within [k_0, k_(count-1)], and where it is attained
+	Min(from, to float64) (x, value float64)
+	// Max Largest value on [from, to] within [k_0, k_(count-1)], and where it is attained
+	Max(from, to float64) (x, value float64)
 }
@@ -1,12 +1,18 @@
 package bspline
 
 import (
+	"errors"
 	"fmt"
 	"image/color"
+	"math"
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 
 	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/ppoly"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/vg"
@@ -14,7 +20,10 @@ import (
 
 func TestSimpleBSpline(t *testing.T) {
 	const order = 3
-	knots := knot.NewUniformKnot(0, 1, 11, order)
+	knots, err := knot.NewUniformKnot(0, 1, 11, order)
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Println(knots)
 
 	p, err := plot.New()
@@ -34,7 +43,10 @@ func TestSimpleBSpline(t *testing.T) {
 
 	for m := 0; m <= order; m++ {
 		coef := make([]float64, knots.Count()+m)
-		simpleSpline := NewBSplineSimple(m, knots, coef)
+		simpleSpline, err := NewBSplineSimple(m, knots, coef)
+		if err != nil {
+			t.Fatal(err)
+		}
 		f := plotter.NewFunction(simpleSpline.GetBSpline(m).Evaluate)
 		f.Samples = 1000
 		col := 255.0 * (float64(m) / float64(order))
@@ -62,7 +74,1273 @@ func TestSimpleBSpline(t *testing.T) {
 	p.Y.Max = 3.5
 
 	// Save the plot to a PNG file.
-	if err := p.Save(6*vg.Inch, 4*vg.Inch, "/Users/shp/Documents/projects/gonaturalspline/bspline/TestSimpleBSpline.png"); err != nil {
+	if err := p.Save(6*vg.Inch, 4*vg.Inch, filepath.Join(os.TempDir(), "TestSimpleBSpline.png")); err != nil {
 		panic(err)
 	}
 }
+
+func TestBSplineErrors(t *testing.T) {
+	knots, err := knot.NewUniformKnot(0, 1, 11, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewBSplineSimple(-1, knots, make([]float64, 10)); !errors.Is(err, splineerr.ErrInvalidOrder) {
+		t.Fatalf("[BSpline] Expected ErrInvalidOrder, got %v", err)
+	}
+	if _, err := NewBSplineSimple(3, knots, make([]float64, 11)); !errors.Is(err, splineerr.ErrCoefLength) {
+		t.Fatalf("[BSpline] Expected ErrCoefLength, got %v", err)
+	}
+	if _, err := NewBSplineSimple(3, nil, nil); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[BSpline] Expected ErrInvalidKnots, got %v", err)
+	}
+}
+
+func TestBSplineDerivative(t *testing.T) {
+	const order = 3
+	knots, err := knot.NewArbitraryKnotBuilder(order, 0, 0.1, 0.3, 0.35, 0.6, 0.8, 1).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coef := []float64{1, -2, 0.5, 3, 2, -1, 0, 1, 2, -0.5}
+	spline, err := NewBSplineSimple(order, knots, coef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const h = 1e-5
+	for k := 1; k <= order; k++ {
+		lower, err := spline.Derivative(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lower.Order() != order-k {
+			t.Fatalf("[BSpline] Order of derivative %d is %d", k, lower.Order())
+		}
+		previous, _ := spline.Derivative(k - 1)
+		// The derivative spline is only valid on [k_0, k_(count-1)]
+		for x := 0.01; x < 1; x += 0.0123 {
+			numeric := (previous.At(x+h) - previous.At(x-h)) / (2 * h)
+			if d := math.Abs(spline.DerivativeAt(x, k) - numeric); d > 1e-4*math.Max(1, math.Abs(numeric)) {
+				t.Fatalf("[BSpline] DerivativeAt(%f, %d) = %f, numeric %f", x, k, spline.DerivativeAt(x, k), numeric)
+			}
+			if d := math.Abs(lower.At(x) - spline.DerivativeAt(x, k)); d > 1e-8*math.Max(1, math.Abs(numeric)) {
+				t.Fatalf("[BSpline] Derivative(%d).At(%f) = %f, DerivativeAt %f", k, x, lower.At(x), spline.DerivativeAt(x, k))
+			}
+		}
+	}
+	if _, err := spline.Derivative(order + 1); !errors.Is(err, splineerr.ErrInvalidOrder) {
+		t.Fatalf("[BSpline] Expected ErrInvalidOrder, got %v", err)
+	}
+}
+
+func TestBSplineIntegral(t *testing.T) {
+	const order = 3
+	knots, err := knot.NewArbitraryKnotBuilder(order, 0, 0.1, 0.3, 0.35, 0.6, 0.8, 1).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coef := []float64{1, -2, 0.5, 3, 2, -1, 0, 1, 2, -0.5}
+	spline, err := NewBSplineSimple(order, knots, coef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	anti, err := spline.Antiderivative()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anti.Order() != order+1 {
+		t.Fatalf("[BSpline] Order of antiderivative is %d", anti.Order())
+	}
+	if d := math.Abs(anti.DerivativeAt(0.45, 1) - spline.At(0.45)); d > 1e-10 {
+		t.Fatalf("[BSpline] Derivative of antiderivative differs by %g", d)
+	}
+
+	// Simpson's rule on a fine grid
+	const steps = 10000
+	a, b := 0.05, 0.93
+	h := (b - a) / steps
+	numeric := spline.At(a) + spline.At(b)
+	for i := 1; i < steps; i++ {
+		w := 2.0
+		if i%2 == 1 {
+			w = 4
+		}
+		numeric += w * spline.At(a+float64(i)*h)
+	}
+	numeric *= h / 3
+	if d := math.Abs(spline.Integral(a, b) - numeric); d > 1e-8 {
+		t.Fatalf("[BSpline] Integral = %f, numeric %f", spline.Integral(a, b), numeric)
+	}
+	if d := spline.Integral(a, b) + spline.Integral(b, a); math.Abs(d) > 1e-12 {
+		t.Fatalf("[BSpline] Integral is not antisymmetric: %g", d)
+	}
+
+	// Bounds outside the knots: the integral stays at its total past the support
+	uniform, err := knot.NewUniformKnot(0, 1, 11, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ones := make([]float64, 14)
+	for j := range ones {
+		ones[j] = 1
+	}
+	spline, err = NewBSplineSimple(order, uniform, ones)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Midpoint rule over the padded support [-0.3, 1.3]
+	const midSteps = 160000
+	h = 1.6 / midSteps
+	var total, right float64
+	for i := 0; i < midSteps; i++ {
+		x := -0.3 + (float64(i)+0.5)*h
+		total += h * spline.At(x)
+		if x > 0 {
+			right += h * spline.At(x)
+		}
+	}
+	if d := math.Abs(spline.Integral(-10, 10) - total); d > 1e-8 {
+		t.Fatalf("[BSpline] Integral over the support = %f, numeric %f", spline.Integral(-10, 10), total)
+	}
+	if d := math.Abs(spline.Integral(0, 5) - right); d > 1e-8 {
+		t.Fatalf("[BSpline] Integral(0, 5) = %f, numeric %f", spline.Integral(0, 5), right)
+	}
+	if v := spline.Integral(1.35, 5); v != 0 {
+		t.Fatalf("[BSpline] Integral right of the support = %g", v)
+	}
+	if v := spline.Integral(-5, -0.35); v != 0 {
+		t.Fatalf("[BSpline] Integral left of the support = %g", v)
+	}
+
+	clamped, err := knot.NewClampedUniformKnot(0, 1, 5, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spline, err = NewBSplineSimple(order, clamped, ones[:7])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, bounds := range [][2]float64{{0, 2}, {-1, 1}, {-1, 2}} {
+		if d := math.Abs(spline.Integral(bounds[0], bounds[1]) - 1); d > 1e-12 {
+			t.Fatalf("[BSpline] Integral(%f, %f) = %f on clamped knots, expected 1", bounds[0], bounds[1], spline.Integral(bounds[0], bounds[1]))
+		}
+	}
+	if d := math.Abs(spline.Integral(-1, 0.5) - 0.5); d > 1e-12 {
+		t.Fatalf("[BSpline] Integral(-1, 0.5) = %f on clamped knots, expected 0.5", spline.Integral(-1, 0.5))
+	}
+
+	// The antiderivative agrees with Integral for bounds up to k_(count-1), also left of the knots
+	uniform, err = knot.NewUniformKnot(0, 1, 5, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spline, err = NewBSplineSimple(order, uniform, []float64{1, 2, 3, 4, 5, 6, 7, 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	anti, err = spline.Antiderivative()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bounds := []float64{-5, -0.5, -0.2, 0, 0.3, 0.9, 1}
+	for _, a := range bounds {
+		for _, b := range bounds {
+			if d := math.Abs(anti.At(b) - anti.At(a) - spline.Integral(a, b)); d > 1e-12 {
+				t.Fatalf("[BSpline] Antiderivative from %f to %f differs from Integral by %g", a, b, d)
+			}
+		}
+	}
+}
+
+func TestBSplineEvalInto(t *testing.T) {
+	const order = 3
+	knots, err := knot.NewArbitraryKnotBuilder(order, 0, 0.1, 0.3, 0.35, 0.6, 0.8, 1).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coef := []float64{1, -2, 0.5, 3, 2, -1, 0, 1, 2, -0.5}
+	spline, err := NewBSplineSimple(order, knots, coef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sorted := make([]float64, 500)
+	for i := range sorted {
+		sorted[i] = -0.2 + 1.4*float64(i)/float64(len(sorted)-1)
+	}
+	unsorted := []float64{0.7, 0.2, 0.2, -0.1, 1.1, 0.99, 0, 0.35, 0.34, 1}
+	for _, xs := range [][]float64{sorted, unsorted} {
+		dst := make([]float64, len(xs))
+		spline.EvalInto(dst, xs)
+		for i, x := range xs {
+			if d := math.Abs(dst[i] - spline.At(x)); d > 1e-12 {
+				t.Fatalf("[BSpline] EvalInto = %f, At = %f at %f", dst[i], spline.At(x), x)
+			}
+		}
+	}
+}
+
+func BenchmarkBSplineAt(b *testing.B) {
+	knots, _ := knot.NewUniformKnot(0, 1, 101, 3)
+	spline, _ := NewBSplineSimple(3, knots, make([]float64, knots.Count()+3))
+	var sink float64
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 1000; j++ {
+			sink += spline.At(float64(j) / 1000)
+		}
+	}
+	_ = sink
+}
+
+func BenchmarkBSplineEvalInto(b *testing.B) {
+	knots, _ := knot.NewUniformKnot(0, 1, 101, 3)
+	spline, _ := NewBSplineSimple(3, knots, make([]float64, knots.Count()+3))
+	xs := make([]float64, 1000)
+	for j := range xs {
+		xs[j] = float64(j) / 1000
+	}
+	dst := make([]float64, len(xs))
+	for i := 0; i < b.N; i++ {
+		spline.EvalInto(dst, xs)
+	}
+}
+
+func TestBSplineClamped(t *testing.T) {
+	for order := 1; order <= 4; order++ {
+		knots, err := knot.NewClampedKnot(order, 0, 0.2, 0.5, 0.6, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		coef := make([]float64, knots.Count()+order-1)
+		for i := range coef {
+			coef[i] = float64(i*i) - 3
+		}
+		spline, err := NewBSplineSimple(order, knots, coef)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := spline.At(0); math.Abs(v-coef[0]) > 1e-12 {
+			t.Fatalf("[BSpline] Order %d: f(0) = %f, expected %f", order, v, coef[0])
+		}
+		if v := spline.At(1); math.Abs(v-coef[len(coef)-1]) > 1e-12 {
+			t.Fatalf("[BSpline] Order %d: f(1) = %f, expected %f", order, v, coef[len(coef)-1])
+		}
+		dst := make([]float64, 2)
+		spline.EvalInto(dst, []float64{0.99, 1})
+		if math.Abs(dst[1]-coef[len(coef)-1]) > 1e-12 {
+			t.Fatalf("[BSpline] Order %d: EvalInto at 1 = %f", order, dst[1])
+		}
+		if v := spline.At(1 + 1e-9); v != 0 {
+			t.Fatalf("[BSpline] Order %d: f is %f beyond the end", order, v)
+		}
+
+		// Partition of unity on the closed interval
+		for _, x := range []float64{0, 0.2, 0.55, 0.999, 1} {
+			_, values := spline.NonzeroBSplines(x)
+			var sum float64
+			for _, v := range values {
+				sum += v
+			}
+			if math.Abs(sum-1) > 1e-12 {
+				t.Fatalf("[BSpline] Order %d: basis functions sum to %f at %f", order, sum, x)
+			}
+		}
+	}
+
+	knots, err := knot.NewClampedUniformKnot(0, 1, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewBSplineSimple(3, knots, make([]float64, knots.Count()+1)); !errors.Is(err, splineerr.ErrCoefLength) {
+		t.Fatalf("[BSpline] Expected ErrCoefLength, got %v", err)
+	}
+}
+
+func TestBSplineMultiplicity(t *testing.T) {
+	const order = 3
+	coef := []float64{1, -2, 0.5, 3, 2, -1, 0, 1, 2, -0.5, 1}
+	limits := func(m int) (left, right, leftSlope, rightSlope float64) {
+		knots, err := knot.NewArbitraryKnotBuilder(order, 0, 0.2, 0.8, 1).AppendWithMultiplicity(0.5, m).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		spline, err := NewBSplineSimple(order, knots, coef[:knots.Count()+order])
+		if err != nil {
+			t.Fatal(err)
+		}
+		const eps = 1e-9
+		return spline.At(0.5 - eps), spline.At(0.5), spline.DerivativeAt(0.5-eps, 1), spline.DerivativeAt(0.5, 1)
+	}
+
+	// C^1 at a double knot
+	left, right, leftSlope, rightSlope := limits(2)
+	if math.Abs(left-right) > 1e-6 || math.Abs(leftSlope-rightSlope) > 1e-6 {
+		t.Fatalf("[BSpline] Not C^1 at a double knot: %f, %f, %f, %f", left, right, leftSlope, rightSlope)
+	}
+	// Kink at a triple knot
+	left, right, leftSlope, rightSlope = limits(order)
+	if math.Abs(left-right) > 1e-6 || math.Abs(leftSlope-rightSlope) < 1e-3 {
+		t.Fatalf("[BSpline] Expected a kink at a knot of multiplicity %d: %f, %f, %f, %f", order, left, right, leftSlope, rightSlope)
+	}
+	// Jump at a knot of multiplicity order+1
+	left, right, _, _ = limits(order + 1)
+	if math.Abs(left-right) < 1e-3 {
+		t.Fatalf("[BSpline] Expected a jump at a knot of multiplicity %d: %f, %f", order+1, left, right)
+	}
+}
+
+func TestBSplinePeriodic(t *testing.T) {
+	const order = 3
+	knots, err := knot.NewPeriodicKnot(order, 0, 0.1, 0.3, 0.35, 0.6, 0.8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coef := []float64{1, -2, 0.5, 3, 2, -1}
+	spline, err := NewPeriodicBSpline(order, knots, coef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// C^2 across the period
+	const eps = 1e-9
+	for k := 0; k < order; k++ {
+		left, right := spline.DerivativeAt(1-eps, k), spline.DerivativeAt(0, k)
+		if math.Abs(left-right) > 1e-5*math.Max(1, math.Abs(right)) {
+			t.Fatalf("[BSpline] Derivative %d is %f before and %f after the period", k, left, right)
+		}
+	}
+	for _, x := range []float64{-0.7, 0.05, 0.33, 0.99} {
+		if d := math.Abs(spline.At(x) - spline.At(x+3)); d > 1e-12 {
+			t.Fatalf("[BSpline] Not periodic at %f: %g", x, d)
+		}
+		var sum float64
+		for j := 0; j < spline.CoefCount(); j++ {
+			sum += spline.GetBSpline(j).Evaluate(x)
+		}
+		if math.Abs(sum-1) > 1e-12 {
+			t.Fatalf("[BSpline] Periodic basis functions sum to %f at %f", sum, x)
+		}
+	}
+
+	xs := []float64{-1.5, -0.2, 0, 0.4, 0.99, 1, 1.3, 2.7}
+	dst := make([]float64, len(xs))
+	spline.EvalInto(dst, xs)
+	for i, x := range xs {
+		if d := math.Abs(dst[i] - spline.At(x)); d > 1e-12 {
+			t.Fatalf("[BSpline] EvalInto = %f, At = %f at %f", dst[i], spline.At(x), x)
+		}
+	}
+
+	derivative, err := spline.Derivative(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := math.Abs(derivative.At(1.45) - spline.DerivativeAt(0.45, 1)); d > 1e-10 {
+		t.Fatalf("[BSpline] Derivative differs by %g", d)
+	}
+	if d := math.Abs(derivative.Integral(-0.3, 2.45) - (spline.At(2.45) - spline.At(-0.3))); d > 1e-10 {
+		t.Fatalf("[BSpline] Integral of derivative differs by %g", d)
+	}
+	if d := math.Abs(spline.Integral(-1, 2) - 3*spline.Integral(0, 1)); d > 1e-10 {
+		t.Fatalf("[BSpline] Integral over 3 periods differs by %g", d)
+	}
+	if _, err := spline.Antiderivative(); !errors.Is(err, splineerr.ErrInvalidArgument) {
+		t.Fatalf("[BSpline] Expected ErrInvalidArgument, got %v", err)
+	}
+	anti, err := derivative.Antiderivative()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := math.Abs(anti.At(0.7) - anti.At(0.2) - (spline.At(0.7) - spline.At(0.2))); d > 1e-10 {
+		t.Fatalf("[BSpline] Antiderivative of derivative differs by %g", d)
+	}
+
+	if _, err := NewPeriodicBSpline(order, knots, coef[1:]); !errors.Is(err, splineerr.ErrCoefLength) {
+		t.Fatalf("[BSpline] Expected ErrCoefLength, got %v", err)
+	}
+}
+
+func TestBSplineInsertKnot(t *testing.T) {
+	const order = 3
+	uniform, err := knot.NewUniformKnot(0, 1, 6, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clamped, err := knot.NewClampedKnot(order, 0, 0.1, 0.3, 0.35, 0.6, 0.8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, knots := range []knot.Knot{uniform, clamped} {
+		spline, err := NewBSplineSimple(order, knots, randomCoefs(knots.Count()+order))
+		if err != nil {
+			t.Fatal(err)
+		}
+		same := func(name string, other BSpline) {
+			for x := -0.1; x <= 1.1; x += 0.001 {
+				if d := math.Abs(spline.At(x) - other.At(x)); d > 1e-10 {
+					t.Fatalf("[BSpline] %s changed the spline at %f by %g", name, x, d)
+				}
+			}
+		}
+
+		inserted, err := spline.InsertKnot(0.45, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if inserted.Knots().Count() != knots.Count()+2 || knot.Multiplicity(inserted.Knots(), 0.45) != 2 {
+			t.Fatalf("[BSpline] Knots after insertion: %v", inserted.Knots())
+		}
+		same("InsertKnot", inserted)
+		if _, err := inserted.InsertKnot(0.45, 3); !errors.Is(err, splineerr.ErrInvalidArgument) {
+			t.Fatalf("[BSpline] Expected ErrInvalidArgument for multiplicity 5, got %v", err)
+		}
+
+		newKnots := []float64{0.05, 0.45, 0.45, 0.6, 0.9, 0.95}
+		refined, err := spline.Refine(newKnots)
+		if err != nil {
+			t.Fatal(err)
+		}
+		same("Refine", refined)
+
+		// Oslo and repeated Boehm agree
+		boehm := BSpline(spline)
+		for _, x := range newKnots {
+			if boehm, err = boehm.InsertKnot(x, 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for j := -order; j < refined.Knots().Count(); j++ {
+			if d := math.Abs(refined.GetCoef(j) - boehm.GetCoef(j)); d > 1e-12 {
+				t.Fatalf("[BSpline] Coefficient %d of Oslo and Boehm differ by %g", j, d)
+			}
+		}
+	}
+
+	knots, err := knot.NewPeriodicKnot(order, 0, 0.1, 0.3, 0.35, 0.6, 0.8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	periodic, err := NewPeriodicBSpline(order, knots, []float64{1, -2, 0.5, 3, 2, -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	refined, err := periodic.Refine([]float64{0.05, 1.5, -0.1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refined.(PeriodicBSpline).CoefCount() != 9 {
+		t.Fatalf("[BSpline] %d periodic coefficients after refinement", refined.(PeriodicBSpline).CoefCount())
+	}
+	for x := -1.0; x <= 2; x += 0.001 {
+		if d := math.Abs(periodic.At(x) - refined.At(x)); d > 1e-10 {
+			t.Fatalf("[BSpline] Periodic refinement changed the spline at %f by %g", x, d)
+		}
+	}
+	if _, err := periodic.InsertKnot(0.3, 1); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[BSpline] Expected ErrInvalidKnots for an existing periodic knot, got %v", err)
+	}
+}
+
+func TestBSplineSimplify(t *testing.T) {
+	const order = 3
+	knots, err := knot.NewClampedKnot(order, 0, 0.1, 0.3, 0.35, 0.6, 0.8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spline, err := NewBSplineSimple(order, knots, randomCoefs(knots.Count()+order-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Inserted knots are removed exactly
+	refined, err := spline.Refine([]float64{0.2, 0.45, 0.45, 0.7, 0.9})
+	if err != nil {
+		t.Fatal(err)
+	}
+	simplified, deviation, err := refined.Simplify(1e-10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if simplified.Knots().Count() != knots.Count() || deviation > 1e-10 {
+		t.Fatalf("[BSpline] %d knots with deviation %g after simplification, expected %d", simplified.Knots().Count(), deviation, knots.Count())
+	}
+	for x := 0.0; x <= 1; x += 0.001 {
+		if d := math.Abs(spline.At(x) - simplified.At(x)); d > 1e-9 {
+			t.Fatalf("[BSpline] Simplified spline differs by %g at %f", d, x)
+		}
+	}
+
+	// A smooth curve on many knots needs only a few
+	dense, err := knot.NewClampedUniformKnot(0, 1, 41, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coef := make([]float64, dense.Count()+order-1)
+	for j := range coef {
+		// Coefficients at the Greville abscissae g reproduce g^2, plus a small wiggle
+		var g float64
+		for i := 1; i <= order; i++ {
+			g += dense.At(j - order + i)
+		}
+		g /= order
+		coef[j] = g*g + 0.001*math.Sin(40*g)
+	}
+	smooth, err := NewBSplineSimple(order, dense, coef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const tol = 0.01
+	simplified, deviation, err = smooth.Simplify(tol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Simplified from %d to %d knots, deviation %g", dense.Count(), simplified.Knots().Count(), deviation)
+	if deviation > tol || simplified.Knots().Count() >= dense.Count()/2 {
+		t.Fatalf("[BSpline] Simplified to %d knots with deviation %g", simplified.Knots().Count(), deviation)
+	}
+	// The deviation is the exact maximum, which a fine grid approaches from below
+	var sampled float64
+	for x := 0.0; x <= 1; x += 0.0001 {
+		sampled = math.Max(sampled, math.Abs(smooth.At(x)-simplified.At(x)))
+	}
+	if sampled > deviation*(1+1e-9) || sampled < deviation*(1-1e-4) {
+		t.Fatalf("[BSpline] Simplified spline differs by %g on a grid, deviation %g", sampled, deviation)
+	}
+
+	periodicKnots, err := knot.NewPeriodicUniformKnot(0, 1, 9, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	periodic, err := NewPeriodicBSpline(order, periodicKnots, randomCoefs(periodicKnots.Count()-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	refined, err = periodic.Refine([]float64{0.3, 0.99})
+	if err != nil {
+		t.Fatal(err)
+	}
+	simplified, deviation, err = refined.Simplify(1e-10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if simplified.Knots().Count() != periodicKnots.Count() || deviation > 1e-10 {
+		t.Fatalf("[BSpline] %d periodic knots with deviation %g after simplification", simplified.Knots().Count(), deviation)
+	}
+	for x := -1.0; x <= 2; x += 0.001 {
+		if d := math.Abs(periodic.At(x) - simplified.At(x)); d > 1e-9 {
+			t.Fatalf("[BSpline] Simplified periodic spline differs by %g at %f", d, x)
+		}
+	}
+
+	if _, _, err := spline.Simplify(-1); !errors.Is(err, splineerr.ErrInvalidArgument) {
+		t.Fatalf("[BSpline] Expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestBSplineElevateReduce(t *testing.T) {
+	const order = 3
+	knots, err := knot.NewArbitraryKnotBuilder(order, 0, 0.1, 0.3, 0.6, 0.8, 1).AppendWithMultiplicity(0.35, 2).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	spline, err := NewBSplineSimple(order, knots, randomCoefs(knots.Count()+order))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for k := 0; k <= 3; k++ {
+		elevated, err := spline.Elevate(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if elevated.Order() != order+k || knot.Multiplicity(elevated.Knots(), 0.35) != 2+k {
+			t.Fatalf("[BSpline] Elevated to order %d with multiplicity %d", elevated.Order(), knot.Multiplicity(elevated.Knots(), 0.35))
+		}
+		for x := 0.0; x <= 1; x += 0.001 {
+			if d := math.Abs(spline.At(x) - elevated.At(x)); d > 1e-10 {
+				t.Fatalf("[BSpline] Elevated by %d differs by %g at %f", k, d, x)
+			}
+		}
+
+		// The original spline is recovered exactly
+		reduced, deviation, err := elevated.Reduce(k, 1e-9)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reduced.Order() != order || reduced.Knots().Count() != knots.Count() || deviation > 1e-9 {
+			t.Fatalf("[BSpline] Reduced to order %d on %d knots with deviation %g", reduced.Order(), reduced.Knots().Count(), deviation)
+		}
+	}
+
+	// Elevation by many orders, and across a break where the spline jumps
+	jump, err := knot.NewArbitraryKnotBuilder(order, 0, 0.2, 0.7, 1).AppendWithMultiplicity(0.5, order+1).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	discontinuous, err := NewBSplineSimple(order, jump, randomCoefs(jump.Count()+order))
+	if err != nil {
+		t.Fatal(err)
+	}
+	elevated, err := discontinuous.Elevate(12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if knot.Multiplicity(elevated.Knots(), 0.5) != order+13 || knot.Multiplicity(elevated.Knots(), 0.2) != 13 {
+		t.Fatalf("[BSpline] Elevated with multiplicities %d and %d", knot.Multiplicity(elevated.Knots(), 0.5), knot.Multiplicity(elevated.Knots(), 0.2))
+	}
+	for x := 0.0; x <= 1; x += 0.001 {
+		if d := math.Abs(discontinuous.At(x) - elevated.At(x)); d > 1e-9 {
+			t.Fatalf("[BSpline] Elevated by 12 differs by %g at %f", d, x)
+		}
+	}
+
+	const tol = 1e-3
+	linear, deviation, err := spline.Reduce(2, tol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("Reduced to order %d on %d knots, deviation %g", linear.Order(), linear.Knots().Count(), deviation)
+	if linear.Order() != 1 || deviation > tol {
+		t.Fatalf("[BSpline] Reduced to order %d with deviation %g", linear.Order(), deviation)
+	}
+	// The deviation is the exact maximum, which a fine grid approaches from below
+	var sampled float64
+	for x := 0.0; x <= 1; x += 0.0001 {
+		sampled = math.Max(sampled, math.Abs(spline.At(x)-linear.At(x)))
+	}
+	if sampled > deviation*(1+1e-9) || sampled < deviation*(1-1e-3) {
+		t.Fatalf("[BSpline] Reduced spline differs by %g on a grid, deviation %g", sampled, deviation)
+	}
+
+	periodicKnots, err := knot.NewPeriodicUniformKnot(0, 1, 9, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	periodic, err := NewPeriodicBSpline(order, periodicKnots, randomCoefs(periodicKnots.Count()-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	quadratic, deviation, err := periodic.Reduce(1, tol)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := quadratic.(PeriodicBSpline); !ok || quadratic.Order() != order-1 || deviation > tol {
+		t.Fatalf("[BSpline] Reduced periodic spline to order %d with deviation %g", quadratic.Order(), deviation)
+	}
+	if d := math.Abs(quadratic.At(-0.25) - periodic.At(0.75)); d > 1.1*tol {
+		t.Fatalf("[BSpline] Reduced periodic spline differs by %g", d)
+	}
+	// Periodic knots are simple, so only k = 0 stays periodic
+	same, err := periodic.Elevate(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := same.(PeriodicBSpline); !ok || math.Abs(same.At(0.3)-periodic.At(0.3)) > 1e-12 {
+		t.Fatalf("[BSpline] Periodic B-Spline elevated by 0 is %T", same)
+	}
+	for _, k := range []int{1, 2} {
+		elevated, err := periodic.Elevate(k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := elevated.(PeriodicBSpline); ok || elevated.Order() != order+k {
+			t.Fatalf("[BSpline] Periodic B-Spline elevated by %d is %T of order %d", k, elevated, elevated.Order())
+		}
+		if m := knot.Multiplicity(elevated.Knots(), 0.5); m != k+1 {
+			t.Fatalf("[BSpline] Periodic B-Spline elevated by %d with multiplicity %d", k, m)
+		}
+		for i := 0; i <= 200; i++ {
+			x := float64(i) / 200
+			if d := math.Abs(elevated.At(x) - periodic.At(x)); d > 1e-10 {
+				t.Fatalf("[BSpline] Periodic B-Spline elevated by %d differs by %g at %f", k, d, x)
+			}
+		}
+	}
+	if _, err := periodic.Elevate(-1); !errors.Is(err, splineerr.ErrInvalidOrder) {
+		t.Fatalf("[BSpline] Expected ErrInvalidOrder, got %v", err)
+	}
+	if _, _, err := spline.Reduce(order+1, tol); !errors.Is(err, splineerr.ErrInvalidOrder) {
+		t.Fatalf("[BSpline] Expected ErrInvalidOrder, got %v", err)
+	}
+}
+
+func TestBSplineArithmetic(t *testing.T) {
+	uniform, err := knot.NewUniformKnot(0, 1, 5, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseline, err := NewBSplineSimple(2, uniform, randomCoefs(uniform.Count()+2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	arbitrary, err := knot.NewArbitraryKnotBuilder(3, 0, 0.1, 0.3, 0.6, 1).AppendWithMultiplicity(0.35, 2).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	deviation, err := NewBSplineSimple(3, arbitrary, randomCoefs(arbitrary.Count()+3))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sum, err := Add(baseline, deviation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	difference, err := Sub(baseline, deviation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scaled, err := Scale(deviation, -2.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	product, err := Mul(baseline, deviation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sum.Order() != 3 || product.Order() != 5 {
+		t.Fatalf("[BSpline] Sum of order %d, product of order %d", sum.Order(), product.Order())
+	}
+	for x := 0.0; x <= 1; x += 0.001 {
+		a, b := baseline.At(x), deviation.At(x)
+		for _, c := range []struct {
+			name     string
+			v, truth float64
+		}{
+			{"Sum", sum.At(x), a + b},
+			{"Difference", difference.At(x), a - b},
+			{"Scaled", scaled.At(x), -2.5 * b},
+			{"Product", product.At(x), a * b},
+		} {
+			if d := math.Abs(c.v - c.truth); d > 1e-9 {
+				t.Fatalf("[BSpline] %s differs by %g at %f", c.name, d, x)
+			}
+		}
+	}
+
+	// Products keep the kink of a knot of multiplicity order
+	kinked, err := knot.NewArbitraryKnotBuilder(2, 0, 1).AppendWithMultiplicity(0.5, 2).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kink, err := NewBSplineSimple(2, kinked, []float64{0, 0, 0, 1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	product, err = Mul(kink, baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, x := range []float64{0.49, 0.5, 0.51, 0.999} {
+		if d := math.Abs(product.At(x) - kink.At(x)*baseline.At(x)); d > 1e-9 {
+			t.Fatalf("[BSpline] Product with a kink differs by %g at %f", d, x)
+		}
+	}
+
+	periodicKnots, err := knot.NewPeriodicKnot(3, 0, 0.25, 0.5, 0.75, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherKnots, err := knot.NewPeriodicKnot(3, 0, 0.2, 0.4, 0.6, 0.8, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seasonal, err := NewPeriodicBSpline(3, periodicKnots, randomCoefs(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewPeriodicBSpline(3, otherKnots, randomCoefs(5))
+	if err != nil {
+		t.Fatal(err)
+	}
+	periodicSum, err := Add(seasonal, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := -1.0; x <= 2; x += 0.001 {
+		if d := math.Abs(periodicSum.At(x) - seasonal.At(x) - other.At(x)); d > 1e-9 {
+			t.Fatalf("[BSpline] Periodic sum differs by %g at %f", d, x)
+		}
+	}
+	if _, err := Add(seasonal, baseline); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[BSpline] Expected ErrInvalidKnots, got %v", err)
+	}
+	if _, err := Mul(seasonal, other); !errors.Is(err, splineerr.ErrInvalidArgument) {
+		t.Fatalf("[BSpline] Expected ErrInvalidArgument for a periodic product, got %v", err)
+	}
+	shifted, err := knot.NewUniformKnot(0.5, 1, 5, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elsewhere, err := NewBSplineSimple(2, shifted, randomCoefs(shifted.Count()+2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Add(baseline, elsewhere); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[BSpline] Expected ErrInvalidKnots for different intervals, got %v", err)
+	}
+}
+
+func TestBSplinePiecewisePolynomial(t *testing.T) {
+	uniform, err := knot.NewUniformKnot(0, 1, 6, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clamped, err := knot.NewClampedKnot(2, 0, 0.2, 0.5, 0.7, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	multiple, err := knot.NewArbitraryKnotBuilder(4, 0, 0.1, 0.6, 1).AppendWithMultiplicity(0.3, 2).AppendWithMultiplicity(0.45, 4).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	periodicKnots, err := knot.NewPeriodicKnot(3, 0, 0.25, 0.5, 0.6, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var splines []BSpline
+	for _, c := range []struct {
+		order int
+		knots knot.Knot
+		count int
+	}{
+		{3, uniform, uniform.Count() + 3},
+		{2, clamped, clamped.Count() + 1},
+		{4, multiple, multiple.Count() + 4},
+		{0, uniform, uniform.Count()},
+	} {
+		spline, err := NewBSplineSimple(c.order, c.knots, randomCoefs(c.count))
+		if err != nil {
+			t.Fatal(err)
+		}
+		splines = append(splines, spline)
+	}
+	periodic, err := NewPeriodicBSpline(3, periodicKnots, randomCoefs(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	splines = append(splines, periodic)
+
+	for _, spline := range splines {
+		pp := spline.ToPiecewisePolynomial()
+		segments := spline.BezierSegments()
+		if pp.Order() != spline.Order() || pp.Pieces() != len(segments) {
+			t.Fatalf("[BSpline] Order %d: piecewise polynomial of order %d, %d pieces, %d segments", spline.Order(), pp.Order(), pp.Pieces(), len(segments))
+		}
+		for i, s := range segments {
+			if s.Start != pp.Breaks[i] || s.End != pp.Breaks[i+1] || !(s.Start < s.End) {
+				t.Fatalf("[BSpline] Segment [%f, %f], breaks [%f, %f]", s.Start, s.End, pp.Breaks[i], pp.Breaks[i+1])
+			}
+			for x := s.Start; x < s.End; x += (s.End - s.Start) / 17 {
+				if d := math.Abs(s.At(x) - spline.At(x)); d > 1e-12 {
+					t.Fatalf("[BSpline] Order %d: Bezier segment differs by %g at %f", spline.Order(), d, x)
+				}
+			}
+		}
+		for x := 0.0; x <= 1; x += 0.001 {
+			if d := math.Abs(pp.At(x) - spline.At(x)); d > 1e-9 {
+				t.Fatalf("[BSpline] Order %d: piecewise polynomial differs by %g at %f", spline.Order(), d, x)
+			}
+		}
+	}
+
+	// Clamped ends interpolate the first and the last coefficient
+	segments := splines[1].BezierSegments()
+	if first, last := segments[0].Control[0], segments[len(segments)-1].Control[2]; first != splines[1].GetCoef(-2) || math.Abs(last-splines[1].At(1)) > 1e-12 {
+		t.Fatalf("[BSpline] Clamped Bezier ends %f, %f", first, last)
+	}
+}
+
+func TestBSplineRoots(t *testing.T) {
+	knots, err := knot.NewUniformKnot(0, 1, 11, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spline, err := NewBSplineSimple(3, knots, randomCoefs(knots.Count()+3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, level := range []float64{-0.2, 0, 0.3} {
+		roots := spline.Roots(level)
+		t.Logf("[BSpline] Roots at level %f: %v", level, roots)
+		for _, r := range roots {
+			if d := math.Abs(spline.At(r) - level); d > 1e-12 {
+				t.Fatalf("[BSpline] Spline at root %f differs by %g from %f", r, d, level)
+			}
+		}
+		// Every change of sign on a fine grid is bracketing exactly one root
+		var crossings int
+		for x := 0.0; x < 1; x += 1e-4 {
+			if (spline.At(x) < level) != (spline.At(x+1e-4) < level) {
+				crossings++
+			}
+		}
+		if crossings != len(roots) {
+			t.Fatalf("[BSpline] %d roots, %d crossings at level %f", len(roots), crossings, level)
+		}
+	}
+
+	// Roots at a knot of multiplicity order+1, where the spline jumps, are not crossings
+	jump, err := knot.NewArbitraryKnotBuilder(1, 0, 1).AppendWithMultiplicity(0.5, 2).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	steps, err := NewBSplineSimple(1, jump, []float64{-1, -1, 1, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roots := steps.Roots(0); len(roots) != 0 {
+		t.Fatalf("[BSpline] Roots of a jump: %v", roots)
+	}
+
+	// A constant has no isolated roots at its own value
+	ones := make([]float64, knots.Count()+3)
+	for j := range ones {
+		ones[j] = 1
+	}
+	constant, err := NewBSplineSimple(3, knots, ones)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roots := constant.Roots(1); len(roots) != 0 {
+		t.Fatalf("[BSpline] Roots of a constant at its value: %v", roots)
+	}
+
+	periodicKnots, err := knot.NewPeriodicUniformKnot(0, 1, 9, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coefs := make([]float64, periodicKnots.Count()-1)
+	for i := range coefs {
+		coefs[i] = math.Cos(2 * math.Pi * (float64(i) + 0.5) / float64(len(coefs)))
+	}
+	periodic, err := NewPeriodicBSpline(3, periodicKnots, coefs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, level := range []float64{0, periodic.At(0)} {
+		roots := periodic.Roots(level)
+		t.Logf("[BSpline] Periodic roots at level %f: %v", level, roots)
+		if len(roots) != 2 {
+			t.Fatalf("[BSpline] %d periodic roots at level %f", len(roots), level)
+		}
+		for _, r := range roots {
+			if r < 0 || r >= 1 || math.Abs(periodic.At(r)-level) > 1e-12 {
+				t.Fatalf("[BSpline] Periodic root %f", r)
+			}
+		}
+	}
+}
+
+func TestBSplineExtrema(t *testing.T) {
+	knots, err := knot.NewUniformKnot(0, 1, 11, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spline, err := NewBSplineSimple(3, knots, randomCoefs(knots.Count()+3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	extrema := spline.Extrema()
+	t.Logf("[BSpline] Extrema %v", extrema)
+	if len(extrema) == 0 {
+		t.Fatal("[BSpline] No extrema")
+	}
+	for i, e := range extrema {
+		if math.Abs(spline.DerivativeAt(e.X, 1)) > 1e-9 {
+			t.Fatalf("[BSpline] Derivative %g at the extremum %f", spline.DerivativeAt(e.X, 1), e.X)
+		}
+		sign := 1.0
+		if e.Kind == ppoly.Minimum {
+			sign = -1
+		}
+		if sign*(spline.At(e.X-1e-3)-e.Value) > 0 || sign*(spline.At(e.X+1e-3)-e.Value) > 0 {
+			t.Fatalf("[BSpline] %v at %f is not one", e.Kind, e.X)
+		}
+		if i > 0 && extrema[i-1].Kind == e.Kind {
+			t.Fatalf("[BSpline] Two %vs in a row at %f", e.Kind, e.X)
+		}
+	}
+	for _, x := range spline.InflectionPoints() {
+		if d := spline.DerivativeAt(x, 2); math.Abs(d) > 1e-9 {
+			t.Fatalf("[BSpline] Second derivative %g at the inflection point %f", d, x)
+		}
+	}
+
+	// Global extrema against a fine grid
+	x, max := spline.Max(-1, 2)
+	_, min := spline.Min(0.2, 0.7)
+	gridMax, gridMin := math.Inf(-1), math.Inf(1)
+	for u := 0.0; u <= 1; u += 1e-4 {
+		gridMax = math.Max(gridMax, spline.At(u))
+		if 0.2 <= u && u <= 0.7 {
+			gridMin = math.Min(gridMin, spline.At(u))
+		}
+	}
+	if max < gridMax || max-gridMax > 1e-6 || min > gridMin || gridMin-min > 1e-6 || spline.At(x) != max {
+		t.Fatalf("[BSpline] Max %f, grid %f, min %f, grid %f", max, gridMax, min, gridMin)
+	}
+
+	// Extrema across the period of a periodic B-Spline
+	periodicKnots, err := knot.NewPeriodicUniformKnot(0, 1, 9, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coefs := make([]float64, periodicKnots.Count()-1)
+	for i := range coefs {
+		coefs[i] = math.Cos(2 * math.Pi * float64(i-1) / float64(len(coefs)))
+	}
+	periodic, err := NewPeriodicBSpline(3, periodicKnots, coefs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The maximum is at the start of the period
+	extrema = periodic.Extrema()
+	t.Logf("[BSpline] Periodic extrema %v", extrema)
+	if len(extrema) != 2 || extrema[0].Kind != ppoly.Maximum || extrema[1].Kind != ppoly.Minimum || math.Abs(extrema[0].X) > 1e-9 {
+		t.Fatalf("[BSpline] Periodic extrema %v", extrema)
+	}
+	if inflections := periodic.InflectionPoints(); len(inflections) != 2 {
+		t.Fatalf("[BSpline] Periodic inflection points %v", inflections)
+	}
+	if x, v := periodic.Max(3.5, 4.6); math.Abs(x-(4+extrema[0].X)) > 1e-9 || math.Abs(v-extrema[0].Value) > 1e-12 {
+		t.Fatalf("[BSpline] Periodic max %f at %f", v, x)
+	}
+	if x, v := periodic.Min(-10, 10); math.Abs(periodic.At(x)-v) > 1e-12 || math.Abs(v-extrema[1].Value) > 1e-12 {
+		t.Fatalf("[BSpline] Periodic min %f at %f", v, x)
+	}
+}
+
+func TestBSplineCurve(t *testing.T) {
+	// The parabola (u, u^2) on [0, 1] as a quadratic Bezier curve
+	clamped, err := knot.NewClampedKnot(2, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parabola, err := NewCurve(2, clamped, [][]float64{{0, 0}, {0.5, 0}, {1, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parabola.Dimension() != 2 || parabola.Order() != 2 {
+		t.Fatalf("[BSpline] Curve of dimension %d, order %d", parabola.Dimension(), parabola.Order())
+	}
+	for u := 0.0; u <= 1; u += 0.01 {
+		p, tangent, normal := parabola.Point(u), parabola.Tangent(u), parabola.Normal(u)
+		norm := math.Sqrt(1 + 4*u*u)
+		kappa := 2 / (norm * norm * norm)
+		for _, c := range []struct {
+			name     string
+			v, truth float64
+		}{
+			{"x", p[0], u},
+			{"y", p[1], u * u},
+			{"Tangent x", tangent[0], 1 / norm},
+			{"Tangent y", tangent[1], 2 * u / norm},
+			{"Normal x", normal[0], -2 * u / norm},
+			{"Normal y", normal[1], 1 / norm},
+			{"Curvature", parabola.Curvature(u), kappa},
+		} {
+			if math.Abs(c.v-c.truth) > 1e-12 {
+				t.Fatalf("[BSpline] %s is %f, expected %f at %f", c.name, c.v, c.truth, u)
+			}
+		}
+	}
+
+	// A straight line in 3 dimensions has no curvature nor normal
+	line, err := NewCurve(2, clamped, [][]float64{{0, 0, 0}, {1, 2, 3}, {2, 4, 6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kappa, normal := line.Curvature(0.3), line.Normal(0.3); kappa > 1e-12 || dot(normal, normal) != 0 {
+		t.Fatalf("[BSpline] Line of curvature %g, normal %v", kappa, normal)
+	}
+
+	// A closed curve approximating the unit circle
+	periodicKnots, err := knot.NewPeriodicUniformKnot(0, 1, 17, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := make([][]float64, periodicKnots.Count()-1)
+	for j := range points {
+		theta := 2 * math.Pi * float64(j) / float64(len(points))
+		points[j] = []float64{math.Cos(theta), math.Sin(theta), 0}
+	}
+	circle, err := NewPeriodicCurve(3, periodicKnots, points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for u := 0.0; u < 1; u += 0.01 {
+		p, q := circle.Point(u), circle.Point(u+1)
+		r := math.Sqrt(dot(p, p))
+		if math.Abs(p[0]-q[0]) > 1e-12 || math.Abs(p[1]-q[1]) > 1e-12 {
+			t.Fatalf("[BSpline] Closed curve at %f is %v, a period later %v", u, p, q)
+		}
+		// The normal points to the center, and the curvature is about 1 / radius
+		normal := circle.Normal(u)
+		if math.Abs(dot(normal, p)/r+1) > 1e-3 || math.Abs(circle.Curvature(u)*r-1) > 0.05 {
+			t.Fatalf("[BSpline] Closed curve at %f has normal %v, curvature %f", u, normal, circle.Curvature(u))
+		}
+	}
+	if x := circle.Coordinate(0); x.At(0.25) != circle.Point(0.25)[0] {
+		t.Fatalf("[BSpline] Coordinate %f, point %v", x.At(0.25), circle.Point(0.25))
+	}
+
+	if _, err := NewCurve(2, clamped, [][]float64{{0, 0}, {1}, {2, 2}}); !errors.Is(err, splineerr.ErrInvalidArgument) {
+		t.Fatalf("[BSpline] Expected ErrInvalidArgument, got %v", err)
+	}
+	if _, err := NewCurve(2, clamped, [][]float64{{0, 0}, {1, 1}}); !errors.Is(err, splineerr.ErrCoefLength) {
+		t.Fatalf("[BSpline] Expected ErrCoefLength, got %v", err)
+	}
+}
+
+func TestBSplineRationalCurve(t *testing.T) {
+	center := []float64{1, -2}
+	circle, err := NewCircle(center, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if circle.Dimension() != 2 || circle.Order() != 2 {
+		t.Fatalf("[BSpline] Circle of dimension %d, order %d", circle.Dimension(), circle.Order())
+	}
+	for u := 0.0; u <= 1; u += 0.001 {
+		p := circle.Point(u)
+		r := math.Hypot(p[0]-center[0], p[1]-center[1])
+		tangent, normal := circle.Tangent(u), circle.Normal(u)
+		radial := []float64{(p[0] - center[0]) / r, (p[1] - center[1]) / r}
+		if math.Abs(r-3) > 1e-12 || math.Abs(circle.Curvature(u)-1.0/3) > 1e-12 {
+			t.Fatalf("[BSpline] Circle of radius %f, curvature %f at %f", r, circle.Curvature(u), u)
+		}
+		// Counterclockwise tangent, and the normal pointing to the center
+		if math.Abs(tangent[0]+radial[1]) > 1e-12 || math.Abs(tangent[1]-radial[0]) > 1e-12 || math.Abs(dot(normal, radial)+1) > 1e-12 {
+			t.Fatalf("[BSpline] Circle at %f has tangent %v, normal %v", u, tangent, normal)
+		}
+	}
+	if p := circle.Point(1); math.Abs(p[0]-4) > 1e-12 || math.Abs(p[1]+2) > 1e-12 {
+		t.Fatalf("[BSpline] Circle ends at %v", p)
+	}
+
+	// Clockwise arc of 100 degrees
+	arc, err := NewArc([]float64{0, 0}, 1, math.Pi/2, math.Pi/2-100*math.Pi/180)
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := arc.Point(1)
+	if math.Abs(end[0]-math.Sin(100*math.Pi/180)) > 1e-12 || math.Abs(end[1]-math.Cos(100*math.Pi/180)) > 1e-12 {
+		t.Fatalf("[BSpline] Arc ends at %v", end)
+	}
+	for u := 0.0; u <= 1; u += 0.01 {
+		if p := arc.Point(u); math.Abs(math.Hypot(p[0], p[1])-1) > 1e-12 || p[0] < -1e-12 {
+			t.Fatalf("[BSpline] Arc at %f is %v", u, p)
+		}
+	}
+
+	// Derivatives of the quotient against central differences
+	ellipse, err := NewConicArc([]float64{0, 0, 0}, []float64{1, 1, 0.5}, []float64{2, 0, 1}, 0.4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const h = 1e-5
+	for u := 0.1; u < 1; u += 0.1 {
+		d1, d2 := ellipse.Derivative(u, 1), ellipse.Derivative(u, 2)
+		left, mid, right := ellipse.Point(u-h), ellipse.Point(u), ellipse.Point(u+h)
+		for i := range d1 {
+			if math.Abs(d1[i]-(right[i]-left[i])/(2*h)) > 1e-6 || math.Abs(d2[i]-(right[i]-2*mid[i]+left[i])/(h*h)) > 1e-3 {
+				t.Fatalf("[BSpline] Conic derivatives %v, %v at %f", d1, d2, u)
+			}
+		}
+	}
+
+	// Weight 1 is the polynomial curve
+	clamped, err := knot.NewClampedKnot(2, 0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := [][]float64{{0, 0}, {0.5, 0}, {1, 1}}
+	parabola, err := NewCurve(2, clamped, points)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conic, err := NewConicArc(points[0], points[1], points[2], 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for u := 0.0; u <= 1; u += 0.05 {
+		if math.Abs(conic.Curvature(u)-parabola.Curvature(u)) > 1e-12 || math.Abs(conic.Point(u)[1]-parabola.Point(u)[1]) > 1e-15 {
+			t.Fatalf("[BSpline] Parabolic conic differs at %f", u)
+		}
+	}
+
+	if _, err := NewRationalCurve(2, clamped, points, []float64{1, 0, 1}); !errors.Is(err, splineerr.ErrInvalidWeights) {
+		t.Fatalf("[BSpline] Expected ErrInvalidWeights, got %v", err)
+	}
+	if _, err := NewRationalCurve(2, clamped, points, []float64{1, 1}); !errors.Is(err, splineerr.ErrDataLength) {
+		t.Fatalf("[BSpline] Expected ErrDataLength, got %v", err)
+	}
+	if _, err := NewArc([]float64{0, 0}, 1, 0, 7); !errors.Is(err, splineerr.ErrInvalidArgument) {
+		t.Fatalf("[BSpline] Expected ErrInvalidArgument, got %v", err)
+	}
+}
+
+func TestBSplineSurface(t *testing.T) {
+	knotsX, err := knot.NewUniformKnot(0, 2, 6, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	knotsY, err := knot.NewClampedKnot(2, -1, -0.2, 0.5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := randomCoefs(knotsX.Count() + 3)
+	b := randomCoefs(knotsY.Count() + 1)
+	for j := range b {
+		b[j] += float64(j)
+	}
+	splineX, err := NewBSplineSimple(3, knotsX, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	splineY, err := NewBSplineSimple(2, knotsY, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// c_ij = a_i * b_j is the product of the B-Splines
+	coefs := make([][]float64, len(a))
+	for i := range coefs {
+		coefs[i] = make([]float64, len(b))
+		for j := range b {
+			coefs[i][j] = a[i] * b[j]
+		}
+	}
+	surface, err := NewSurface(3, knotsX, 2, knotsY, coefs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ox, oy := surface.Orders(); ox != 3 || oy != 2 {
+		t.Fatalf("[BSpline] Surface of orders %d, %d", ox, oy)
+	}
+	if nx, ny := surface.CoefCount(); nx != len(a) || ny != len(b) || surface.GetCoef(2, 3) != a[2]*b[3] {
+		t.Fatalf("[BSpline] Surface of %dx%d coefficients", nx, ny)
+	}
+	for x := 0.0; x <= 2; x += 0.05 {
+		for y := -1.0; y <= 1; y += 0.05 {
+			for _, k := range [][2]int{{0, 0}, {1, 0}, {0, 1}, {1, 1}, {2, 1}} {
+				v := surface.DerivativeAt(x, y, k[0], k[1])
+				truth := splineX.DerivativeAt(x, k[0]) * splineY.DerivativeAt(y, k[1])
+				if math.Abs(v-truth) > 1e-12 {
+					t.Fatalf("[BSpline] Surface derivative %v is %f, expected %f at (%f, %f)", k, v, truth, x, y)
+				}
+			}
+		}
+	}
+
+	surface.SetCoef(2, 3, 0)
+	if v := surface.At(1, 0); math.Abs(v-(splineX.At(1)*splineY.At(0)-a[2]*b[3]*splineX.GetBSpline(2).Evaluate(1)*splineY.GetBSpline(3).Evaluate(0))) > 1e-12 {
+		t.Fatalf("[BSpline] Surface after SetCoef is %f", v)
+	}
+
+	coefs[1] = coefs[1][:2]
+	if _, err := NewSurface(3, knotsX, 2, knotsY, coefs); !errors.Is(err, splineerr.ErrCoefLength) {
+		t.Fatalf("[BSpline] Expected ErrCoefLength, got %v", err)
+	}
+}
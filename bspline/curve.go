@@ -0,0 +1,156 @@
+package bspline
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+)
+
+// Curve Parametric B-Spline curve
+//     c(u) = sum_j P_j * B_j(u)
+// with control points P_j of any dimension, each coordinate being a B-Spline on the same knots.
+type Curve struct {
+	coords []BSpline
+}
+
+// NewCurve Curve of the given order on the knots through the control points, as NewBSplineSimple.
+// Requires len(points) == knot.Count() + order, or one less on clamped knots, and points of the same positive dimension.
+func NewCurve(order int, knot knot.Knot, points [][]float64) (*Curve, error) {
+	return newCurve(points, func(coef []float64) (BSpline, error) {
+		return NewBSplineSimple(order, knot, coef)
+	})
+}
+
+// NewPeriodicCurve Closed curve of the given order on the periodic knots, as NewPeriodicBSpline.
+// Requires len(points) == knot.Count() - 1, and points of the same positive dimension.
+func NewPeriodicCurve(order int, knot knot.PeriodicKnot, points [][]float64) (*Curve, error) {
+	return newCurve(points, func(coef []float64) (BSpline, error) {
+		return NewPeriodicBSpline(order, knot, coef)
+	})
+}
+
+// newCurve Curve of the coordinates of points built as B-Splines by build
+func newCurve(points [][]float64, build func(coef []float64) (BSpline, error)) (*Curve, error) {
+	if len(points) == 0 || len(points[0]) == 0 {
+		return nil, fmt.Errorf("[BSpline] Curve without control points: %w", splineerr.ErrCoefLength)
+	}
+	dim := len(points[0])
+	for j, p := range points {
+		if len(p) != dim {
+			return nil, fmt.Errorf("[BSpline] Control point %d of dimension %d, %d expected: %w", j, len(p), dim, splineerr.ErrInvalidArgument)
+		}
+	}
+	coords := make([]BSpline, dim)
+	for i := range coords {
+		coef := make([]float64, len(points))
+		for j, p := range points {
+			coef[j] = p[i]
+		}
+		coord, err := build(coef)
+		if err != nil {
+			return nil, err
+		}
+		coords[i] = coord
+	}
+	return &Curve{coords: coords}, nil
+}
+
+// Dimension Dimension of the control points
+func (c *Curve) Dimension() int {
+	return len(c.coords)
+}
+
+func (c *Curve) Order() int {
+	return c.coords[0].Order()
+}
+
+func (c *Curve) Knots() knot.Knot {
+	return c.coords[0].Knots()
+}
+
+// Coordinate B-Spline of the i-th coordinate of the curve, sharing its control points
+func (c *Curve) Coordinate(i int) BSpline {
+	return c.coords[i]
+}
+
+// Point c(u)
+func (c *Curve) Point(u float64) []float64 {
+	return c.Derivative(u, 0)
+}
+
+// Derivative k-th derivative of c at u
+func (c *Curve) Derivative(u float64, k int) []float64 {
+	v := make([]float64, len(c.coords))
+	for i, coord := range c.coords {
+		v[i] = coord.DerivativeAt(u, k)
+	}
+	return v
+}
+
+// Tangent Unit tangent c'(u) / |c'(u)|, zero where c'(u) vanishes
+func (c *Curve) Tangent(u float64) []float64 {
+	return normalize(c.Derivative(u, 1))
+}
+
+// Normal Principal unit normal, the part of c''(u) orthogonal to c'(u) normalized,
+// pointing to the center of curvature. Zero where the curvature vanishes.
+func (c *Curve) Normal(u float64) []float64 {
+	return principalNormal(c.Derivative(u, 1), c.Derivative(u, 2))
+}
+
+// Curvature Unsigned curvature at u
+//     kappa = sqrt(|c'|^2 * |c''|^2 - (c' . c'')^2) / |c'|^3
+// which is |c' x c''| / |c'|^3 in 3 dimensions. Zero where c'(u) vanishes.
+func (c *Curve) Curvature(u float64) float64 {
+	return curvature(c.Derivative(u, 1), c.Derivative(u, 2))
+}
+
+// principalNormal Part of d2 orthogonal to d1 normalized, written to d2
+func principalNormal(d1, d2 []float64) []float64 {
+	s := dot(d1, d1)
+	if s == 0 {
+		return make([]float64, len(d1))
+	}
+	r := dot(d1, d2) / s
+	for i := range d2 {
+		d2[i] -= r * d1[i]
+	}
+	return normalize(d2)
+}
+
+// curvature Curvature of a curve of the first and second derivatives d1, d2
+func curvature(d1, d2 []float64) float64 {
+	s := dot(d1, d1)
+	if s == 0 {
+		return 0
+	}
+	r := dot(d1, d2)
+	area := s*dot(d2, d2) - r*r
+	if area < 0 {
+		// Rounding off of parallel derivatives
+		area = 0
+	}
+	return math.Sqrt(area) / (s * math.Sqrt(s))
+}
+
+func dot(a, b []float64) float64 {
+	var s float64
+	for i := range a {
+		s += a[i] * b[i]
+	}
+	return s
+}
+
+// normalize v / |v| in place, or v if it is zero
+func normalize(v []float64) []float64 {
+	norm := math.Sqrt(dot(v, v))
+	if norm == 0 {
+		return v
+	}
+	for i := range v {
+		v[i] /= norm
+	}
+	return v
+}
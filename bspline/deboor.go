@@ -0,0 +1,136 @@
+package bspline
+
+// stackOrder Orders up to which the evaluation buffers are kept on the stack
+const stackOrder = 15
+
+// knot t_i of the knot vector
+func (b *bSplineSimple) knot(i int) float64 {
+	return b.t[i+b.order]
+}
+
+// span Index s such that t_s <= x < t_(s+1), so that B_(s-order), ... , B_s
+// are the only basis functions which may be nonzero at x.
+// The right end of the support belongs to the last nonempty span,
+// so that splines on clamped knots are defined on the closed interval.
+// Returns false if x is outside the support of every basis function.
+func (b *bSplineSimple) span(x float64) (int, bool) {
+	last := len(b.coefs) + b.order - 1
+	if x < b.knot(0) || x > b.knot(last+1) {
+		return 0, false
+	}
+	if x == b.knot(last+1) {
+		s := last
+		for s > 0 && b.knot(s) >= x {
+			s--
+		}
+		return s, b.knot(s) < x
+	}
+	// Index finds k_idx < x <= k_(idx+1), and t_s = k_(s-order)
+	s := b.knots.Index(x) + b.order
+	if s < 0 {
+		s = 0
+	}
+	if s > last {
+		s = last
+	}
+	for s > 0 && b.knot(s) > x {
+		s--
+	}
+	for s < last && b.knot(s+1) <= x {
+		s++
+	}
+	return s, true
+}
+
+// spanFrom span(x), searched forward from the span s of a smaller abscissa.
+// Falls back to span(x) if s is not a span at or before x.
+func (b *bSplineSimple) spanFrom(x float64, s int) (int, bool) {
+	last := len(b.coefs) + b.order - 1
+	if s < 0 || s > last || x < b.knot(s) {
+		return b.span(x)
+	}
+	if x >= b.knot(last+1) {
+		return b.span(x)
+	}
+	for s < last && b.knot(s+1) <= x {
+		s++
+	}
+	return s, true
+}
+
+// basisFuns Cox-de Boor triangular scheme for the basis functions
+// B_(s-order), ... , B_s at x in the span s, written to values.
+// Reference: Algorithm A2.2, L. Piegl and W. Tiller, The NURBS Book
+func (b *bSplineSimple) basisFuns(s int, x float64, values []float64) {
+	p := b.order
+	var leftBuf, rightBuf [stackOrder + 1]float64
+	left, right := leftBuf[:], rightBuf[:]
+	if p > stackOrder {
+		left, right = make([]float64, p+1), make([]float64, p+1)
+	}
+
+	values[0] = 1
+	for j := 1; j <= p; j++ {
+		left[j] = x - b.knot(s+1-j)
+		right[j] = b.knot(s+j) - x
+		var saved float64
+		for r := 0; r < j; r++ {
+			temp := values[r] / (right[r+1] + left[j-r])
+			values[r] = saved + right[r+1]*temp
+			saved = left[j-r] * temp
+		}
+		values[j] = saved
+	}
+}
+
+// evaluate k-th derivative at x of sum_j c_j * B_j, where
+// c_j = coefs[j - first] if it exists, or 0 otherwise.
+// The local coefficients are differenced k times, then evaluated by de Boor's algorithm.
+func (b *bSplineSimple) evaluate(x float64, k int, coefs []float64, first int) float64 {
+	p := b.order
+	if k < 0 || k > p {
+		return 0
+	}
+	s, ok := b.span(x)
+	if !ok {
+		return 0
+	}
+	return b.evaluateSpan(x, s, k, coefs, first)
+}
+
+// evaluateSpan evaluate at x in the span s
+func (b *bSplineSimple) evaluateSpan(x float64, s, k int, coefs []float64, first int) float64 {
+	p := b.order
+	var buf [stackOrder + 1]float64
+	d := buf[:]
+	if p > stackOrder {
+		d = make([]float64, p+1)
+	}
+	// d_i = c_(s-p+i)
+	for i := 0; i <= p; i++ {
+		if j := s - p + i - first; 0 <= j && j < len(coefs) {
+			d[i] = coefs[j]
+		} else {
+			d[i] = 0
+		}
+	}
+
+	// Derivative of order q: d_i <- q * (d_(i+1) - d_i) / (t_(j+q) - t_j), j = s-q+1+i
+	q := p
+	for ; q > p-k; q-- {
+		for i := 0; i < q; i++ {
+			j := s - q + 1 + i
+			d[i] = float64(q) * (d[i+1] - d[i]) / (b.knot(j+q) - b.knot(j))
+		}
+	}
+
+	// de Boor's algorithm for order q, with d_i = c_(s-q+i)
+	for r := 1; r <= q; r++ {
+		for i := q; i >= r; i-- {
+			j := s - q + i
+			alpha := (x - b.knot(j)) / (b.knot(j+q+1-r) - b.knot(j))
+			d[i] = (1-alpha)*d[i-1] + alpha*d[i]
+		}
+	}
+	return d[q]
+}
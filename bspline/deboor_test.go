@@ -0,0 +1,196 @@
+package bspline
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/helloworldpark/gonaturalspline/knot"
+)
+
+// recursiveBSpline Former evaluation of bSplineSimple, walking a tree of
+// basis functions built by the Cox-de Boor recursion. Kept as a reference.
+type recursiveBSpline struct {
+	knots    knot.Knot
+	order    int
+	bsplines []BSplineFunc
+	coefs    []float64
+}
+
+func newRecursiveBSpline(order int, knots knot.Knot, coefs []float64) *recursiveBSpline {
+	return &recursiveBSpline{
+		knots:    knots,
+		order:    order,
+		bsplines: buildRecursiveBSplines(order, knots),
+		coefs:    coefs,
+	}
+}
+
+func (b *recursiveBSpline) At(x float64) float64 {
+	idx := b.knots.Index(x)
+	var v float64
+	for m := -b.order; m <= 0; m++ {
+		j := idx + m + b.order
+		if 0 <= j && j < len(b.coefs) {
+			v += b.coefs[j] * b.bsplines[j].Evaluate(x)
+		}
+	}
+	return v
+}
+
+type bSplineHaar struct {
+	knots [2]float64
+}
+
+func (b *bSplineHaar) Evaluate(x float64) float64 {
+	if b.knots[0] <= x && x < b.knots[1] {
+		return 1
+	}
+	return 0
+}
+
+func (b *bSplineHaar) Derivative(x float64, k int) float64 {
+	if k == 0 {
+		return b.Evaluate(x)
+	}
+	return 0
+}
+
+type bSplineOrder struct {
+	leftRamp   BSplineFunc
+	rightRamp  BSplineFunc
+	leftKnots  [2]float64 // ti, ti+o
+	rightKnots [2]float64 // ti+1, ti+o+1
+	order      int
+}
+
+func (b *bSplineOrder) Evaluate(x float64) float64 {
+	var y float64
+	if b.leftKnots[0] != b.leftKnots[1] {
+		y += ((x - b.leftKnots[0]) / (b.leftKnots[1] - b.leftKnots[0])) * b.leftRamp.Evaluate(x)
+	}
+	if b.rightKnots[0] != b.rightKnots[1] {
+		y += ((b.rightKnots[1] - x) / (b.rightKnots[1] - b.rightKnots[0])) * b.rightRamp.Evaluate(x)
+	}
+	return y
+}
+
+func (b *bSplineOrder) Derivative(x float64, k int) float64 {
+	if k == 0 {
+		return b.Evaluate(x)
+	}
+	var y float64
+	if b.leftKnots[0] != b.leftKnots[1] {
+		y += b.leftRamp.Derivative(x, k-1) / (b.leftKnots[1] - b.leftKnots[0])
+	}
+	if b.rightKnots[0] != b.rightKnots[1] {
+		y -= b.rightRamp.Derivative(x, k-1) / (b.rightKnots[1] - b.rightKnots[0])
+	}
+	return float64(b.order) * y
+}
+
+func buildRecursiveBSplines(order int, knots knot.Knot) []BSplineFunc {
+	var splines []BSplineFunc
+	// Order 0
+	for idx := -order; idx < knots.Count()+order; idx++ {
+		k1, k2 := knots.At(idx), knots.At(idx+1)
+		splines = append(splines, &bSplineHaar{knots: [2]float64{k1, k2}})
+	}
+
+	// Order 1~ : Recursive
+	for m := 1; m <= order; m++ {
+		for idx := -order; idx < knots.Count()+order-m; idx++ {
+			a1, a2 := knots.At(idx), knots.At(idx+m)
+			t1, t2 := knots.At(idx+1), knots.At(idx+m+1)
+			splines[idx+order] = &bSplineOrder{
+				leftRamp:   splines[idx+order],
+				rightRamp:  splines[idx+1+order],
+				order:      m,
+				leftKnots:  [2]float64{a1, a2},
+				rightKnots: [2]float64{t1, t2},
+			}
+		}
+	}
+	return splines[:knots.Count()+order]
+}
+
+func randomCoefs(n int) []float64 {
+	rnd := rand.New(rand.NewSource(1))
+	coefs := make([]float64, n)
+	for i := range coefs {
+		coefs[i] = rnd.NormFloat64()
+	}
+	return coefs
+}
+
+func TestDeBoorMatchesRecursion(t *testing.T) {
+	for order := 0; order <= 5; order++ {
+		for _, builder := range []*knot.ArbitraryKnotBuilder{
+			knot.NewArbitraryKnotBuilder(order, 0, 0.1, 0.3, 0.35, 0.6, 0.8, 1),
+			knot.NewArbitraryKnotBuilder(order, 0, 0.1, 0.6, 0.8, 1).AppendWithMultiplicity(0.3, 2).AppendWithMultiplicity(0.35, order+1),
+		} {
+			testDeBoorMatchesRecursion(t, order, builder)
+		}
+	}
+}
+
+func testDeBoorMatchesRecursion(t *testing.T, order int, builder *knot.ArbitraryKnotBuilder) {
+	knots, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	coefs := randomCoefs(knots.Count() + order)
+	spline, err := NewBSplineSimple(order, knots, coefs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reference := newRecursiveBSpline(order, knots, coefs)
+
+	for x := -0.2; x < 1.2; x += 0.0037 {
+		if d := math.Abs(spline.At(x) - reference.At(x)); d > 1e-12 {
+			t.Fatalf("[BSpline] Order %d: de Boor %f, recursion %f at %f", order, spline.At(x), reference.At(x), x)
+		}
+		for j := range coefs {
+			for k := 0; k <= order; k++ {
+				v, r := spline.GetBSpline(j).Derivative(x, k), reference.bsplines[j].Derivative(x, k)
+				if d := math.Abs(v - r); d > 1e-9*math.Max(1, math.Abs(r)) {
+					t.Fatalf("[BSpline] Order %d: derivative %d of B_%d is %f, recursion %f at %f", order, k, j, v, r, x)
+				}
+			}
+		}
+		first, values := spline.NonzeroBSplines(x)
+		for i, v := range values {
+			if d := math.Abs(v - reference.bsplines[first+i].Evaluate(x)); d > 1e-12 {
+				t.Fatalf("[BSpline] Order %d: nonzero B_%d is %f at %f", order, first+i, v, x)
+			}
+		}
+	}
+}
+
+func benchmarkAt(bench *testing.B, order int, at func(order int, knots knot.Knot, coefs []float64) func(float64) float64) {
+	knots, err := knot.NewUniformKnot(0, 1, 101, order)
+	if err != nil {
+		bench.Fatal(err)
+	}
+	f := at(order, knots, randomCoefs(knots.Count()+order))
+	bench.ResetTimer()
+	var sink float64
+	for i := 0; i < bench.N; i++ {
+		sink += f(float64(i%1000) / 1000)
+	}
+	_ = sink
+}
+
+func deBoorAt(order int, knots knot.Knot, coefs []float64) func(float64) float64 {
+	spline, _ := NewBSplineSimple(order, knots, coefs)
+	return spline.At
+}
+
+func recursiveAt(order int, knots knot.Knot, coefs []float64) func(float64) float64 {
+	return newRecursiveBSpline(order, knots, coefs).At
+}
+
+func BenchmarkDeBoorAtOrder3(b *testing.B)    { benchmarkAt(b, 3, deBoorAt) }
+func BenchmarkRecursiveAtOrder3(b *testing.B) { benchmarkAt(b, 3, recursiveAt) }
+func BenchmarkDeBoorAtOrder5(b *testing.B)    { benchmarkAt(b, 5, deBoorAt) }
+func BenchmarkRecursiveAtOrder5(b *testing.B) { benchmarkAt(b, 5, recursiveAt) }
@@ -0,0 +1,287 @@
+package bspline
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+	"gonum.org/v1/gonum/integrate/quad"
+	"gonum.org/v1/gonum/mat"
+)
+
+// maxReduceRounds Times the knot spans are halved in Reduce before giving up on the tolerance
+const maxReduceRounds = 12
+
+// Elevate B-Spline of order+k equal to b on [k_0, k_(count-1)].
+// Every interior knot gains multiplicity k to keep the continuity of b, and the end knots are clamped.
+// b is split into its Bézier segments, each of which is elevated, and the knots joining them
+// are removed again down to their multiplicity plus k, which is exact.
+// Reference: Algorithm A5.9, L. Piegl and W. Tiller, The NURBS Book
+func (b *bSplineSimple) Elevate(k int) (BSpline, error) {
+	if k < 0 {
+		return nil, fmt.Errorf("[BSpline] Elevate by %d: %w", k, splineerr.ErrInvalidOrder)
+	}
+	p, q := b.order, b.order+k
+	breaks, multiplicities := b.breaks()
+	segments := b.BezierSegments()
+
+	// The elevated segments joined at the breaks with multiplicity q, or q+1 where b is discontinuous
+	var sequence, coefs []float64
+	for i, u := range breaks {
+		m := q
+		if i == 0 || i == len(breaks)-1 || multiplicities[i] > p {
+			m = q + 1
+		}
+		for r := 0; r < m; r++ {
+			sequence = append(sequence, u)
+		}
+		if i == len(breaks)-1 {
+			break
+		}
+		control := elevateBezier(segments[i].Control, k)
+		if m == q {
+			// The segments meet at the same control point
+			control = control[1:]
+		}
+		coefs = append(coefs, control...)
+	}
+	knots, err := knot.NewSequenceKnot(q, sequence...)
+	if err != nil {
+		return nil, err
+	}
+
+	elevated := newBSplineSimple(q, knots, coefs)
+	for i := 1; i+1 < len(breaks); i++ {
+		if multiplicities[i] > p {
+			continue
+		}
+		for s := q; s > multiplicities[i]+k; s-- {
+			// r: index of the last copy of the break in the knot vector t
+			r := 0
+			for elevated.knot(r+1) <= breaks[i] {
+				r++
+			}
+			if elevated, err = elevated.removeKnot(r, s); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return elevated, nil
+}
+
+// elevateBezier Control points of degree p+k of the Bézier curve of the control points of degree p
+//     Q_i = sum_j C(p, j) * C(k, i - j) / C(p + k, i) * P_j
+// Reference: Equation (5.36), L. Piegl and W. Tiller, The NURBS Book
+func elevateBezier(control []float64, k int) []float64 {
+	p := len(control) - 1
+	elevated := make([]float64, p+k+1)
+	for i := range elevated {
+		for j := 0; j <= p; j++ {
+			if i-j < 0 || i-j > k {
+				continue
+			}
+			elevated[i] += binomial(p, j) * binomial(k, i-j) / binomial(p+k, i) * control[j]
+		}
+	}
+	return elevated
+}
+
+// binomial C(n, k)
+func binomial(n, k int) float64 {
+	c := 1.0
+	for i := 1; i <= k; i++ {
+		c = c * float64(n-k+i) / float64(i)
+	}
+	return c
+}
+
+// breaks Distinct knots in [k_0, k_(count-1)] and their multiplicities there
+func (b *bSplineSimple) breaks() ([]float64, []int) {
+	var breaks []float64
+	var multiplicities []int
+	for i := 0; i < b.knots.Count(); i++ {
+		u := b.knots.At(i)
+		if len(breaks) > 0 && breaks[len(breaks)-1] == u {
+			multiplicities[len(multiplicities)-1]++
+			continue
+		}
+		breaks = append(breaks, u)
+		multiplicities = append(multiplicities, 1)
+	}
+	return breaks, multiplicities
+}
+
+// Reduce B-Spline of order-k approximating b on [k_0, k_(count-1)] within tol, and the deviation.
+// It is the least squares approximation of b on the same knots, the multiplicities reduced by k
+// and the end knots clamped. Knot spans deviating more than tol are halved, up to maxReduceRounds times.
+// The deviation is measured as in Simplify.
+func (b *bSplineSimple) Reduce(k int, tol float64) (BSpline, float64, error) {
+	if k < 0 || k > b.order {
+		return nil, 0, fmt.Errorf("[BSpline] Reduce order %d by %d: %w", b.order, k, splineerr.ErrInvalidOrder)
+	}
+	if tol < 0 || math.IsNaN(tol) {
+		return nil, 0, fmt.Errorf("[BSpline] Tolerance %f: %w", tol, splineerr.ErrInvalidArgument)
+	}
+	q := b.order - k
+	breaks, multiplicities := b.breaks()
+	build := func(breaks []float64, multiplicities []int) (*bSplineSimple, error) {
+		var sequence []float64
+		for i, u := range breaks {
+			m := multiplicities[i] - k
+			if m < 1 {
+				m = 1
+			}
+			if i == 0 || i == len(breaks)-1 || m > q+1 {
+				m = q + 1
+			}
+			for ; m > 0; m-- {
+				sequence = append(sequence, u)
+			}
+		}
+		knots, err := knot.NewSequenceKnot(q, sequence...)
+		if err != nil {
+			return nil, err
+		}
+		return newBSplineSimple(q, knots, make([]float64, knots.Count()+q-1)), nil
+	}
+	return reduce(b, tol, breaks, multiplicities, func(breaks []float64, multiplicities []int) (BSpline, int, error) {
+		spline, err := build(breaks, multiplicities)
+		if err != nil {
+			return nil, 0, err
+		}
+		return spline, len(spline.coefs), nil
+	})
+}
+
+// reduce Least squares approximations of f on the spaces built on breaks,
+// halving the spans deviating more than tol. build returns a spline with the coefficients
+// to be set, and how many there are; basis functions wrap around them.
+func reduce(f BSpline, tol float64, breaks []float64, multiplicities []int, build func([]float64, []int) (BSpline, int, error)) (BSpline, float64, error) {
+	var deviation float64
+	for round := 0; round <= maxReduceRounds; round++ {
+		g, dim, err := build(breaks, multiplicities)
+		if err != nil {
+			return nil, 0, err
+		}
+		if err := project(f.At, f.Order(), g, dim, breaks); err != nil {
+			return nil, 0, err
+		}
+
+		difference := f.ToPiecewisePolynomial().Sub(g.ToPiecewisePolynomial())
+		deviation = 0
+		var nextBreaks []float64
+		var nextMultiplicities []int
+		for i := range breaks {
+			nextBreaks = append(nextBreaks, breaks[i])
+			nextMultiplicities = append(nextMultiplicities, multiplicities[i])
+			if i == len(breaks)-1 {
+				break
+			}
+			// Up to the left limit at the end of the span
+			d := maxAbs(difference, breaks[i], math.Nextafter(breaks[i+1], breaks[i]))
+			if d > deviation {
+				deviation = d
+			}
+			if d > tol {
+				nextBreaks = append(nextBreaks, (breaks[i]+breaks[i+1])/2)
+				nextMultiplicities = append(nextMultiplicities, 1)
+			}
+		}
+		if deviation <= tol {
+			return g, deviation, nil
+		}
+		breaks, multiplicities = nextBreaks, nextMultiplicities
+	}
+	return nil, deviation, fmt.Errorf("[BSpline] Deviation %g exceeds tolerance %g: %w", deviation, tol, splineerr.ErrInvalidArgument)
+}
+
+// project Set the coefficients of g to those of the least squares approximation of f
+//     minimize integral of (f(x) - g(x))^2 dx over [breaks_0, breaks_last]
+// f should be a polynomial of the degree on each span of breaks, so that Gauss-Legendre quadrature is exact.
+func project(f func(float64) float64, degree int, g BSpline, dim int, breaks []float64) error {
+	// m nodes integrate polynomials up to degree 2m-1 exactly
+	m := (degree+g.Order())/2 + 1
+	nodes := make([]float64, m)
+	weights := make([]float64, m)
+
+	G := mat.NewSymDense(dim, nil)
+	rhs := mat.NewVecDense(dim, nil)
+	for i := 0; i+1 < len(breaks); i++ {
+		quad.Legendre{}.FixedLocations(nodes, weights, breaks[i], breaks[i+1])
+		for n, x := range nodes {
+			first, values := g.NonzeroBSplines(x)
+			fx := f(x)
+			for r, vr := range values {
+				jr := (first + r) % dim
+				rhs.SetVec(jr, rhs.AtVec(jr)+weights[n]*vr*fx)
+				for c, vc := range values {
+					jc := (first + c) % dim
+					if jc >= jr {
+						G.SetSym(jr, jc, G.At(jr, jc)+weights[n]*vr*vc)
+					}
+				}
+			}
+		}
+	}
+
+	var chol mat.Cholesky
+	if ok := chol.Factorize(G); !ok {
+		return &splineerr.SingularSystemError{Cond: mat.Cond(G, 1)}
+	}
+	var coefs mat.VecDense
+	if err := chol.SolveVecTo(&coefs, rhs); err != nil {
+		if _, ok := err.(mat.Condition); !ok {
+			return &splineerr.SingularSystemError{Cond: chol.Cond()}
+		}
+	}
+	for j := 0; j < dim; j++ {
+		g.SetCoef(j, coefs.AtVec(j))
+	}
+	return nil
+}
+
+// Elevate Periodic B-Spline equal to b for k = 0. Periodic knots are simple,
+// and cannot keep the continuity of b at a higher order, which needs every knot of multiplicity k+1.
+// For k > 0 it is a non-periodic B-Spline on the knots of one period, clamped at the ends
+// and of multiplicity k+1 inside, equal to b on [k_0, k_(count-1)]; see bSplineSimple.Elevate.
+func (b *bSplinePeriodic) Elevate(k int) (BSpline, error) {
+	if k < 0 {
+		return nil, fmt.Errorf("[BSpline] Elevate by %d: %w", k, splineerr.ErrInvalidOrder)
+	}
+	if k > 0 {
+		return b.spline.Elevate(k)
+	}
+	return newBSplinePeriodic(b.spline.order, b.spline.knots, append([]float64{}, b.coefs...)), nil
+}
+
+// Reduce Periodic B-Spline of order-k approximating b within tol, and the deviation.
+// See bSplineSimple.Reduce.
+func (b *bSplinePeriodic) Reduce(k int, tol float64) (BSpline, float64, error) {
+	if k < 0 || k > b.spline.order {
+		return nil, 0, fmt.Errorf("[BSpline] Reduce order %d by %d: %w", b.spline.order, k, splineerr.ErrInvalidOrder)
+	}
+	if tol < 0 || math.IsNaN(tol) {
+		return nil, 0, fmt.Errorf("[BSpline] Tolerance %f: %w", tol, splineerr.ErrInvalidArgument)
+	}
+	knots := b.spline.knots
+	breaks := make([]float64, knots.Count())
+	multiplicities := make([]int, knots.Count())
+	for i := range breaks {
+		breaks[i] = knots.At(i)
+		multiplicities[i] = 1
+	}
+	q := b.spline.order - k
+	return reduce(b, tol, breaks, multiplicities, func(breaks []float64, _ []int) (BSpline, int, error) {
+		sort.Float64s(breaks)
+		periodic, err := knot.NewPeriodicKnot(knots.Padding(), breaks...)
+		if err != nil {
+			return nil, 0, err
+		}
+		if periodic.Count()-1 <= q {
+			return nil, 0, fmt.Errorf("[BSpline] Periodic B-Spline of order %d needs more than %d knot spans: %w", q, q, splineerr.ErrInvalidKnots)
+		}
+		return newBSplinePeriodic(q, periodic, make([]float64, periodic.Count()-1)), periodic.Count() - 1, nil
+	})
+}
@@ -0,0 +1,182 @@
+package bspline
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+)
+
+// RationalCurve Non-uniform rational B-Spline (NURBS) curve
+//     c(u) = sum_j w_j * P_j * B_j(u) / sum_j w_j * B_j(u)
+// with positive weights w_j. It is the projection of the polynomial Curve of the
+// homogeneous control points (w_j * P_j, w_j), which is evaluated instead.
+// Unlike polynomial curves, rational curves represent conics, and circles, exactly.
+type RationalCurve struct {
+	// homogeneous Curve of (w_j * P_j, w_j)
+	homogeneous *Curve
+}
+
+// NewRationalCurve Rational curve of the given order on the knots through the control points
+// with the weights, as NewCurve. Requires len(weights) == len(points) positive weights.
+func NewRationalCurve(order int, knot knot.Knot, points [][]float64, weights []float64) (*RationalCurve, error) {
+	homogeneous, err := homogeneousPoints(points, weights)
+	if err != nil {
+		return nil, err
+	}
+	curve, err := NewCurve(order, knot, homogeneous)
+	if err != nil {
+		return nil, err
+	}
+	return &RationalCurve{homogeneous: curve}, nil
+}
+
+// NewPeriodicRationalCurve Closed rational curve of the given order on the periodic knots,
+// as NewPeriodicCurve. Requires len(weights) == len(points) positive weights.
+func NewPeriodicRationalCurve(order int, knot knot.PeriodicKnot, points [][]float64, weights []float64) (*RationalCurve, error) {
+	homogeneous, err := homogeneousPoints(points, weights)
+	if err != nil {
+		return nil, err
+	}
+	curve, err := NewPeriodicCurve(order, knot, homogeneous)
+	if err != nil {
+		return nil, err
+	}
+	return &RationalCurve{homogeneous: curve}, nil
+}
+
+// homogeneousPoints (w_j * P_j, w_j)
+func homogeneousPoints(points [][]float64, weights []float64) ([][]float64, error) {
+	if len(weights) != len(points) {
+		return nil, fmt.Errorf("[BSpline] %d control points, %d weights: %w", len(points), len(weights), splineerr.ErrDataLength)
+	}
+	homogeneous := make([][]float64, len(points))
+	for j, p := range points {
+		w := weights[j]
+		if !(w > 0) || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("[BSpline] Weight %f: %w", w, splineerr.ErrInvalidWeights)
+		}
+		homogeneous[j] = make([]float64, len(p)+1)
+		for i, v := range p {
+			homogeneous[j][i] = w * v
+		}
+		homogeneous[j][len(p)] = w
+	}
+	return homogeneous, nil
+}
+
+// NewConicArc Quadratic rational Bézier curve from p0 to p2 on [0, 1], tangent to p1 - p0 and p2 - p1
+// at the ends, with the weight w of p1. The arc is elliptic if w < 1, parabolic if w = 1 and
+// hyperbolic if w > 1; with w = cos(theta / 2) and |p1 - p0| = |p2 - p1| it is a circular arc of angle theta.
+func NewConicArc(p0, p1, p2 []float64, w float64) (*RationalCurve, error) {
+	knots, err := knot.NewClampedKnot(2, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	return NewRationalCurve(2, knots, [][]float64{p0, p1, p2}, []float64{1, w, 1})
+}
+
+// NewArc Circular arc in the plane of the center and the radius, from the angle start to end
+// in radians, counterclockwise if end > start, on [0, 1].
+// It is joined from quadratic conic arcs of at most a quarter turn, each on an equal part of [0, 1],
+// with double knots between them. Requires radius > 0 and 0 < |end - start| <= 2 pi.
+// Reference: Algorithm A7.1, L. Piegl and W. Tiller, The NURBS Book
+func NewArc(center []float64, radius, start, end float64) (*RationalCurve, error) {
+	if len(center) != 2 {
+		return nil, fmt.Errorf("[BSpline] Center of dimension %d for a planar arc: %w", len(center), splineerr.ErrInvalidArgument)
+	}
+	sweep := end - start
+	if !(radius > 0) || math.IsInf(radius, 0) || !(sweep != 0 && math.Abs(sweep) <= 2*math.Pi) {
+		return nil, fmt.Errorf("[BSpline] Arc of radius %f from %f to %f: %w", radius, start, end, splineerr.ErrInvalidArgument)
+	}
+	n := int(math.Ceil(math.Abs(sweep)/(math.Pi/2) - 1e-12))
+	delta := sweep / float64(n)
+	w := math.Cos(delta / 2)
+
+	point := func(theta, r float64) []float64 {
+		return []float64{center[0] + r*math.Cos(theta), center[1] + r*math.Sin(theta)}
+	}
+	points := [][]float64{point(start, radius)}
+	weights := []float64{1}
+	sequence := []float64{0, 0, 0}
+	for i := 0; i < n; i++ {
+		theta := start + float64(i)*delta
+		points = append(points, point(theta+delta/2, radius/w), point(theta+delta, radius))
+		weights = append(weights, w, 1)
+		u := float64(i+1) / float64(n)
+		sequence = append(sequence, u, u)
+	}
+	// The last knot closes the clamped sequence
+	sequence = append(sequence, 1)
+
+	knots, err := knot.NewSequenceKnot(2, sequence...)
+	if err != nil {
+		return nil, err
+	}
+	return NewRationalCurve(2, knots, points, weights)
+}
+
+// NewCircle Full circle of the center and the radius, counterclockwise from the angle 0, on [0, 1]
+func NewCircle(center []float64, radius float64) (*RationalCurve, error) {
+	return NewArc(center, radius, 0, 2*math.Pi)
+}
+
+// Dimension Dimension of the control points
+func (c *RationalCurve) Dimension() int {
+	return c.homogeneous.Dimension() - 1
+}
+
+func (c *RationalCurve) Order() int {
+	return c.homogeneous.Order()
+}
+
+func (c *RationalCurve) Knots() knot.Knot {
+	return c.homogeneous.Knots()
+}
+
+// Point c(u)
+func (c *RationalCurve) Point(u float64) []float64 {
+	return c.Derivative(u, 0)
+}
+
+// Derivative k-th derivative of c at u, from the derivatives of the homogeneous curve (A, w) by
+//     c^(k) = (A^(k) - sum_(i=1..k) C(k, i) * w^(i) * c^(k-i)) / w
+// Reference: Algorithm A4.2, L. Piegl and W. Tiller, The NURBS Book
+func (c *RationalCurve) Derivative(u float64, k int) []float64 {
+	dim := c.Dimension()
+	weights := make([]float64, k+1)
+	derivatives := make([][]float64, k+1)
+	for m := 0; m <= k; m++ {
+		a := c.homogeneous.Derivative(u, m)
+		weights[m] = a[dim]
+		v := a[:dim]
+		binomial := 1.0
+		for i := 1; i <= m; i++ {
+			binomial = binomial * float64(m-i+1) / float64(i)
+			for j := range v {
+				v[j] -= binomial * weights[i] * derivatives[m-i][j]
+			}
+		}
+		for j := range v {
+			v[j] /= weights[0]
+		}
+		derivatives[m] = v
+	}
+	return derivatives[k]
+}
+
+// Tangent Unit tangent c'(u) / |c'(u)|, zero where c'(u) vanishes
+func (c *RationalCurve) Tangent(u float64) []float64 {
+	return normalize(c.Derivative(u, 1))
+}
+
+// Normal Principal unit normal, pointing to the center of curvature. See Curve.Normal.
+func (c *RationalCurve) Normal(u float64) []float64 {
+	return principalNormal(c.Derivative(u, 1), c.Derivative(u, 2))
+}
+
+// Curvature Unsigned curvature at u. See Curve.Curvature.
+func (c *RationalCurve) Curvature(u float64) float64 {
+	return curvature(c.Derivative(u, 1), c.Derivative(u, 2))
+}
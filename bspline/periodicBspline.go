@@ -0,0 +1,206 @@
+package bspline
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+)
+
+// PeriodicBSpline B-Spline on periodic knots whose coefficients are tied cyclically,
+// so that it is as smooth across the period as anywhere else.
+// There is one independent coefficient per knot span; coefficient j of the
+// B-Spline unwrapped on the knots, as in NewBSplineSimple, is tied to j mod CoefCount().
+// SetCoef and GetBSpline take the independent index, and GetBSpline wraps it around.
+type PeriodicBSpline interface {
+	BSpline
+	Period() float64
+	// CoefCount Number of independent coefficients, knots.Count() - 1
+	CoefCount() int
+}
+
+type bSplinePeriodic struct {
+	// spline Unwrapped B-Spline with the tied coefficients
+	spline *bSplineSimple
+	// coefs Independent coefficients
+	coefs  []float64
+	period float64
+}
+
+// NewPeriodicBSpline Periodic B-Spline of the given order on the knots.
+// Requires order >= 0, knot.Count() - 1 > order and len(coef) == knot.Count() - 1.
+func NewPeriodicBSpline(order int, knot knot.PeriodicKnot, coef []float64) (PeriodicBSpline, error) {
+	if order < 0 {
+		return nil, fmt.Errorf("[BSpline] Negative order %d: %w", order, splineerr.ErrInvalidOrder)
+	}
+	if knot == nil || knot.Count()-1 <= order {
+		return nil, fmt.Errorf("[BSpline] Periodic B-Spline of order %d needs more than %d knot spans: %w", order, order, splineerr.ErrInvalidKnots)
+	}
+	if len(coef) != knot.Count()-1 {
+		return nil, fmt.Errorf("[BSpline] %d coefficients for %d periodic basis functions: %w", len(coef), knot.Count()-1, splineerr.ErrCoefLength)
+	}
+	return newBSplinePeriodic(order, knot, coef), nil
+}
+
+// newBSplinePeriodic Periodic B-Spline without validation of the arguments
+func newBSplinePeriodic(order int, knot knot.Knot, coef []float64) *bSplinePeriodic {
+	n := len(coef)
+	full := make([]float64, knot.Count()+order)
+	for j := range full {
+		full[j] = coef[j%n]
+	}
+	return &bSplinePeriodic{
+		spline: newBSplineSimple(order, knot, full),
+		coefs:  coef,
+		period: knot.At(knot.Count()-1) - knot.At(0),
+	}
+}
+
+// wrap x into [k_0, k_(count-1))
+func (b *bSplinePeriodic) wrap(x float64) float64 {
+	start := b.spline.knots.At(0)
+	r := math.Mod(x-start, b.period)
+	if r < 0 {
+		r += b.period
+	}
+	if r >= b.period {
+		r = 0
+	}
+	return start + r
+}
+
+func (b *bSplinePeriodic) Period() float64 {
+	return b.period
+}
+
+func (b *bSplinePeriodic) CoefCount() int {
+	return len(b.coefs)
+}
+
+func (b *bSplinePeriodic) At(x float64) float64 {
+	return b.spline.At(b.wrap(x))
+}
+
+// EvalInto dst[i] = At(xs[i]). Sorted xs are walked span by span, within each period.
+func (b *bSplinePeriodic) EvalInto(dst, xs []float64) {
+	if len(dst) < len(xs) {
+		panic(fmt.Sprintf("[BSpline] EvalInto: %d destinations for %d abscissae", len(dst), len(xs)))
+	}
+	s := -1
+	for i, x := range xs {
+		x = b.wrap(x)
+		next, ok := b.spline.spanFrom(x, s)
+		if !ok {
+			dst[i] = 0
+			continue
+		}
+		s = next
+		dst[i] = b.spline.evaluateSpan(x, s, 0, b.spline.coefs, 0)
+	}
+}
+
+// DerivativeAt k-th derivative at x
+func (b *bSplinePeriodic) DerivativeAt(x float64, k int) float64 {
+	return b.spline.DerivativeAt(b.wrap(x), k)
+}
+
+// NonzeroBSplines Values of the periodic basis functions which may be nonzero at x.
+// The index first + i may exceed CoefCount(), and wraps around in GetBSpline.
+func (b *bSplinePeriodic) NonzeroBSplines(x float64) (int, []float64) {
+	return b.spline.NonzeroBSplines(b.wrap(x))
+}
+
+// Derivative k-th derivative as a periodic B-Spline of order-k on the same knots
+func (b *bSplinePeriodic) Derivative(k int) (BSpline, error) {
+	d, err := b.spline.Derivative(k)
+	if err != nil {
+		return nil, err
+	}
+	// The coefficients of the derivative are tied like those of b
+	coefs := make([]float64, len(b.coefs))
+	copy(coefs, d.(*bSplineSimple).coefs)
+	return newBSplinePeriodic(b.spline.order-k, b.spline.knots, coefs), nil
+}
+
+// Antiderivative Periodic B-Spline of order+1 whose derivative is b.
+// Exists only if the integral over a period vanishes.
+func (b *bSplinePeriodic) Antiderivative() (BSpline, error) {
+	anti := b.spline.antiderivative()
+	n := len(b.coefs)
+	// e_(j+n) - e_j is the integral over a period
+	var scale float64
+	for j := 0; j < n; j++ {
+		scale += math.Abs(anti.coefs[j+1] - anti.coefs[j])
+	}
+	if d := anti.coefs[n] - anti.coefs[0]; math.Abs(d) > 1e-12*scale {
+		return nil, fmt.Errorf("[BSpline] Integral %g over a period is not 0, antiderivative is not periodic: %w", d, splineerr.ErrInvalidArgument)
+	}
+	coefs := make([]float64, n)
+	copy(coefs, anti.coefs)
+	return newBSplinePeriodic(b.spline.order+1, b.spline.knots, coefs), nil
+}
+
+// Integral Definite integral from "from" to "to", over as many periods as needed
+func (b *bSplinePeriodic) Integral(from, to float64) float64 {
+	anti := b.spline.antiderivative()
+	start := b.spline.knots.At(0)
+	perPeriod := anti.At(start+b.period) - anti.At(start)
+	primitive := func(x float64) float64 {
+		q := math.Floor((x - start) / b.period)
+		return q*perPeriod + anti.At(b.wrap(x)) - anti.At(start)
+	}
+	return primitive(to) - primitive(from)
+}
+
+func (b *bSplinePeriodic) Knots() knot.Knot {
+	return b.spline.knots
+}
+
+func (b *bSplinePeriodic) Order() int {
+	return b.spline.order
+}
+
+// SetCoef Set the independent coefficient of the index, and all coefficients tied to it.
+// Ignored if out of range.
+func (b *bSplinePeriodic) SetCoef(idx int, v float64) {
+	if idx < 0 || idx >= len(b.coefs) {
+		return
+	}
+	b.coefs[idx] = v
+	for j := idx; j < len(b.spline.coefs); j += len(b.coefs) {
+		b.spline.coefs[j] = v
+	}
+}
+
+// GetCoef Coefficient of the basis function supported on [k_idx, k_(idx+order+1)], for any idx
+func (b *bSplinePeriodic) GetCoef(idx int) float64 {
+	n := len(b.coefs)
+	return b.coefs[((idx+b.spline.order)%n+n)%n]
+}
+
+// GetBSpline Periodic basis function of the index modulo CoefCount()
+func (b *bSplinePeriodic) GetBSpline(idx int) BSplineFunc {
+	n := len(b.coefs)
+	return &bSplinePeriodicBasis{spline: b, idx: (idx%n + n) % n}
+}
+
+type bSplinePeriodicBasis struct {
+	spline *bSplinePeriodic
+	idx    int
+}
+
+func (b *bSplinePeriodicBasis) Evaluate(x float64) float64 {
+	return b.Derivative(x, 0)
+}
+
+// Derivative Sum of the derivatives of the tied basis functions
+func (b *bSplinePeriodicBasis) Derivative(x float64, k int) float64 {
+	spline := b.spline.spline
+	x = b.spline.wrap(x)
+	var y float64
+	for j := b.idx; j < len(spline.coefs); j += len(b.spline.coefs) {
+		y += spline.evaluate(x, k, unitCoef, j)
+	}
+	return y
+}
@@ -0,0 +1,230 @@
+package bspline
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+)
+
+// InsertKnot Equivalent B-Spline on the knots with x inserted times more, by Boehm's algorithm
+//     Q_i = alpha_i * P_i + (1 - alpha_i) * P_(i-1),  alpha_i = (x - t_i) / (t_(i+order) - t_i)
+// for the order basis functions nonzero at x; the others keep their coefficients.
+// x should be in [k_0, k_(count-1)], and its multiplicity may not exceed order+1.
+func (b *bSplineSimple) InsertKnot(x float64, times int) (BSpline, error) {
+	if err := b.checkInsertion([]float64{x}, times); err != nil {
+		return nil, err
+	}
+	spline := b
+	for ; times > 0; times-- {
+		var err error
+		if spline, err = spline.insertKnot(x); err != nil {
+			return nil, err
+		}
+	}
+	if spline == b {
+		return newBSplineSimple(b.order, b.knots, append([]float64{}, b.coefs...)), nil
+	}
+	return spline, nil
+}
+
+func (b *bSplineSimple) insertKnot(x float64) (*bSplineSimple, error) {
+	p := b.order
+	last := len(b.coefs) + p - 1
+	// k: t_k <= x < t_(k+1)
+	k := -1
+	for s := last; s >= 0; s-- {
+		if b.knot(s) <= x && x < b.knot(s+1) {
+			k = s
+			break
+		}
+	}
+	if k < 0 {
+		return nil, fmt.Errorf("[BSpline] No knot span contains %f: %w", x, splineerr.ErrInvalidArgument)
+	}
+
+	coefs := make([]float64, len(b.coefs)+1)
+	for i := range coefs {
+		switch {
+		case i <= k-p:
+			coefs[i] = b.coefs[i]
+		case i > k:
+			coefs[i] = b.coefs[i-1]
+		default:
+			alpha := (x - b.knot(i)) / (b.knot(i+p) - b.knot(i))
+			var prev float64
+			if i > 0 {
+				prev = b.coefs[i-1]
+			}
+			coefs[i] = alpha*b.coefs[i] + (1-alpha)*prev
+		}
+	}
+
+	knots, err := insertKnots(b.knots, []float64{x})
+	if err != nil {
+		return nil, err
+	}
+	return newBSplineSimple(p, knots, coefs), nil
+}
+
+// Refine Equivalent B-Spline on the knots with newKnots inserted, by the Oslo algorithm.
+// Each new coefficient is the blossom of the spline at the new knots
+//     Q_i = B[f](tau_(i+1), ... , tau_(i+order))
+// evaluated on a knot span of the old knots.
+// newKnots should be in [k_0, k_(count-1)], and no multiplicity may exceed order+1.
+func (b *bSplineSimple) Refine(newKnots []float64) (BSpline, error) {
+	if err := b.checkInsertion(newKnots, 1); err != nil {
+		return nil, err
+	}
+	knots, err := insertKnots(b.knots, newKnots)
+	if err != nil {
+		return nil, err
+	}
+	refined := newBSplineSimple(b.order, knots, make([]float64, len(b.coefs)+len(newKnots)))
+	coef := func(j int) float64 {
+		if 0 <= j && j < len(b.coefs) {
+			return b.coefs[j]
+		}
+		return 0
+	}
+	for i := range refined.coefs {
+		v, ok := refined.newSpanStart(i)
+		if !ok {
+			// Basis function vanishing everywhere, as the last one on clamped knots
+			refined.coefs[i] = coef(i - len(newKnots))
+			continue
+		}
+		mu, _ := b.span(v)
+		refined.coefs[i] = blossom(b.order, b.knot, coef, mu, refined.t[i+1+b.order:i+1+2*b.order])
+	}
+	return refined, nil
+}
+
+// newSpanStart Left end of a nonempty knot span in the support of the basis function i
+func (b *bSplineSimple) newSpanStart(i int) (float64, bool) {
+	for m := i; m <= i+b.order; m++ {
+		if b.knot(m) < b.knot(m+1) {
+			return b.knot(m), true
+		}
+	}
+	return 0, false
+}
+
+// checkInsertion Whether each of xs inserted times keeps the knots valid for the order
+func (b *bSplineSimple) checkInsertion(xs []float64, times int) error {
+	if times < 0 {
+		return fmt.Errorf("[BSpline] Insert a knot %d times: %w", times, splineerr.ErrInvalidArgument)
+	}
+	start, end := b.knots.At(0), b.knots.At(b.knots.Count()-1)
+	added := make(map[float64]int)
+	for _, x := range xs {
+		if !(start <= x && x <= end) {
+			return fmt.Errorf("[BSpline] Knot %f is outside [%f, %f]: %w", x, start, end, splineerr.ErrInvalidArgument)
+		}
+		added[x] += times
+	}
+	for x, m := range added {
+		var existing int
+		for i := 0; i <= len(b.coefs)+b.order; i++ {
+			if b.knot(i) == x {
+				existing++
+			}
+		}
+		if existing+m > b.order+1 {
+			return fmt.Errorf("[BSpline] Multiplicity %d of knot %f exceeds order+1: %w", existing+m, x, splineerr.ErrInvalidArgument)
+		}
+	}
+	return nil
+}
+
+// blossom Blossom of the polynomial piece of sum_j coef(j) * B_j on the span mu at u,
+// de Boor's algorithm with the argument u[r-1] at the step r
+func blossom(order int, t func(int) float64, coef func(int) float64, mu int, u []float64) float64 {
+	var buf [stackOrder + 1]float64
+	d := buf[:]
+	if order > stackOrder {
+		d = make([]float64, order+1)
+	}
+	for i := 0; i <= order; i++ {
+		d[i] = coef(mu - order + i)
+	}
+	for r := 1; r <= order; r++ {
+		for i := order; i >= r; i-- {
+			j := mu - order + i
+			alpha := (u[r-1] - t(j)) / (t(j+order+1-r) - t(j))
+			d[i] = (1-alpha)*d[i-1] + alpha*d[i]
+		}
+	}
+	return d[order]
+}
+
+// insertKnots Knots with xs inserted, keeping the paddings
+func insertKnots(knots knot.Knot, xs []float64) (knot.Knot, error) {
+	sequence := make([]float64, 0, knots.Len()+len(xs))
+	for i := -knots.Padding(); i < knots.Count()+knots.Padding(); i++ {
+		sequence = append(sequence, knots.At(i))
+	}
+	sequence = append(sequence, xs...)
+	sort.Float64s(sequence)
+	return knot.NewSequenceKnot(knots.Padding(), sequence...)
+}
+
+// InsertKnot Equivalent periodic B-Spline with x inserted into every period.
+// Periodic knots are simple, so x may not be a knot and times may not exceed 1.
+func (b *bSplinePeriodic) InsertKnot(x float64, times int) (BSpline, error) {
+	if times < 0 || times > 1 {
+		return nil, fmt.Errorf("[BSpline] Insert a periodic knot %d times: %w", times, splineerr.ErrInvalidArgument)
+	}
+	if times == 0 {
+		return b.Refine(nil)
+	}
+	return b.Refine([]float64{x})
+}
+
+// Refine Equivalent periodic B-Spline with newKnots inserted into every period, by the Oslo algorithm.
+// newKnots are wrapped into the period, and may not be knots nor repeated.
+func (b *bSplinePeriodic) Refine(newKnots []float64) (BSpline, error) {
+	knots := b.spline.knots
+	breaks := make([]float64, 0, knots.Count()+len(newKnots))
+	for i := 0; i < knots.Count(); i++ {
+		breaks = append(breaks, knots.At(i))
+	}
+	for _, x := range newKnots {
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return nil, fmt.Errorf("[BSpline] Knot %f: %w", x, splineerr.ErrInvalidArgument)
+		}
+		breaks = append(breaks, b.wrap(x))
+	}
+	sort.Float64s(breaks)
+	refinedKnots, err := knot.NewPeriodicKnot(knots.Padding(), breaks...)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(b.coefs)
+	coef := func(j int) float64 {
+		return b.coefs[(j%n+n)%n]
+	}
+	t := func(i int) float64 {
+		return knots.At(i - b.spline.order)
+	}
+	refined := newBSplinePeriodic(b.spline.order, refinedKnots, make([]float64, n+len(newKnots)))
+	for i := range refined.coefs {
+		v, _ := refined.spline.newSpanStart(i)
+		// Span of the old knots in the period of v
+		w := b.wrap(v)
+		mu, _ := b.spline.span(w)
+		mu += int(math.Round((v-w)/b.period)) * n
+		u := make([]float64, b.spline.order)
+		for r := range u {
+			u[r] = refined.spline.knot(i + 1 + r)
+		}
+		refined.coefs[i] = blossom(b.spline.order, t, coef, mu, u)
+	}
+	for j := range refined.spline.coefs {
+		refined.spline.coefs[j] = refined.coefs[j%len(refined.coefs)]
+	}
+	return refined, nil
+}
@@ -0,0 +1,142 @@
+package bspline
+
+import (
+	"math"
+
+	"github.com/helloworldpark/gonaturalspline/ppoly"
+)
+
+// Roots Abscissae in [k_0, k_(count-1)] where b equals level, in increasing order,
+// isolated on the polynomial pieces of b. See ppoly.PiecewisePolynomial.Roots.
+func (b *bSplineSimple) Roots(level float64) []float64 {
+	return b.ToPiecewisePolynomial().Roots(level)
+}
+
+// Roots Abscissae in one period [k_0, k_(count-1)) where b equals level, in increasing order.
+// Add multiples of the period for the others.
+func (b *bSplinePeriodic) Roots(level float64) []float64 {
+	roots := b.spline.Roots(level)
+	start, tol := b.spline.knots.At(0), b.tolerance()
+	end := b.spline.knots.At(b.spline.knots.Count() - 1)
+	if n := len(roots); n > 0 && end-roots[n-1] <= tol {
+		// The end of the period is its start, where the root may have been rounded off
+		roots = roots[:n-1]
+		if len(roots) == 0 || roots[0]-start > tol {
+			roots = append([]float64{start}, roots...)
+		}
+	}
+	return roots
+}
+
+// Extrema Strict local minima and maxima in the interior of [k_0, k_(count-1)], in increasing order.
+// See ppoly.PiecewisePolynomial.Extrema.
+func (b *bSplineSimple) Extrema() []ppoly.Extremum {
+	return b.ToPiecewisePolynomial().Extrema()
+}
+
+// InflectionPoints Abscissae in the interior of [k_0, k_(count-1)] where the second derivative
+// changes sign, in increasing order
+func (b *bSplineSimple) InflectionPoints() []float64 {
+	return b.ToPiecewisePolynomial().InflectionPoints()
+}
+
+// Min Smallest value of b on [from, to] and where it is attained.
+// [from, to] is clamped to [k_0, k_(count-1)].
+func (b *bSplineSimple) Min(from, to float64) (float64, float64) {
+	return b.ToPiecewisePolynomial().Min(b.clamp(from), b.clamp(to))
+}
+
+// Max Largest value of b on [from, to] and where it is attained, see Min
+func (b *bSplineSimple) Max(from, to float64) (float64, float64) {
+	return b.ToPiecewisePolynomial().Max(b.clamp(from), b.clamp(to))
+}
+
+// clamp x into [k_0, k_(count-1)]
+func (b *bSplineSimple) clamp(x float64) float64 {
+	return math.Max(b.knots.At(0), math.Min(x, b.knots.At(b.knots.Count()-1)))
+}
+
+// Extrema Strict local minima and maxima in one period [k_0, k_(count-1)), in increasing order.
+// They are searched on the period preceded by another, so that k_0 is in the interior.
+func (b *bSplinePeriodic) Extrema() []ppoly.Extremum {
+	extrema := b.unrolled(-1, 0).Extrema()
+	start := b.spline.knots.At(0)
+	for i, e := range extrema {
+		if e.X >= start-b.tolerance() {
+			extrema = extrema[i:]
+			if extrema[0].X < start {
+				extrema[0].X = start
+			}
+			return extrema
+		}
+	}
+	return nil
+}
+
+// InflectionPoints Abscissae in one period [k_0, k_(count-1)) where the second derivative
+// changes sign, in increasing order, searched as in Extrema
+func (b *bSplinePeriodic) InflectionPoints() []float64 {
+	xs := b.unrolled(-1, 0).InflectionPoints()
+	start := b.spline.knots.At(0)
+	for i, x := range xs {
+		if x >= start-b.tolerance() {
+			xs = xs[i:]
+			xs[0] = math.Max(xs[0], start)
+			return xs
+		}
+	}
+	return nil
+}
+
+// Min Smallest value of b on [from, to] and where it is attained.
+// A range longer than the period is cut to one period from "from".
+func (b *bSplinePeriodic) Min(from, to float64) (float64, float64) {
+	from, to = b.periodRange(from, to)
+	return b.unrolled(b.periodOf(from), b.periodOf(to)).Min(from, to)
+}
+
+// Max Largest value of b on [from, to] and where it is attained, see Min
+func (b *bSplinePeriodic) Max(from, to float64) (float64, float64) {
+	from, to = b.periodRange(from, to)
+	return b.unrolled(b.periodOf(from), b.periodOf(to)).Max(from, to)
+}
+
+// periodRange [from, to] sorted, and cut to one period
+func (b *bSplinePeriodic) periodRange(from, to float64) (float64, float64) {
+	if from > to {
+		from, to = to, from
+	}
+	if to-from > b.period {
+		to = from + b.period
+	}
+	return from, to
+}
+
+// periodOf m such that x is in [k_0 + m * period, k_(count-1) + m * period)
+func (b *bSplinePeriodic) periodOf(x float64) int {
+	return int(math.Floor((x - b.spline.knots.At(0)) / b.period))
+}
+
+// tolerance Distance within which roots are merged, as in ppoly
+func (b *bSplinePeriodic) tolerance() float64 {
+	start, end := b.spline.knots.At(0), b.spline.knots.At(b.spline.knots.Count()-1)
+	return ppoly.RootTolerance * (end - start + math.Abs(start) + math.Abs(end))
+}
+
+// unrolled Polynomial pieces of b on the periods first, ... , last,
+// the period m being [k_0 + m * period, k_(count-1) + m * period]
+func (b *bSplinePeriodic) unrolled(first, last int) *ppoly.PiecewisePolynomial {
+	pp := b.spline.ToPiecewisePolynomial()
+	n := pp.Pieces()
+	breaks := make([]float64, 0, (last-first+1)*n+1)
+	coefs := make([][]float64, 0, (last-first+1)*n)
+	for m := first; m <= last; m++ {
+		shift := float64(m) * b.period
+		for i := 0; i < n; i++ {
+			breaks = append(breaks, pp.Breaks[i]+shift)
+			coefs = append(coefs, pp.Coefs[i])
+		}
+	}
+	breaks = append(breaks, pp.Breaks[n]+float64(last)*b.period)
+	return &ppoly.PiecewisePolynomial{Breaks: breaks, Coefs: coefs}
+}
@@ -1,35 +1,187 @@
 package bspline
 
 import (
+	"fmt"
+
 	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
 )
 
 type bSplineSimple struct {
-	knots    knot.Knot
-	order    int
-	bsplines []BSplineFunc
-	coefs    []float64
+	knots knot.Knot
+	order int
+	coefs []float64
+	// t Knot vector t_i = knots.At(i - order), stored from i = -order
+	t []float64
 }
 
-func NewBSplineSimple(order int, knot knot.Knot, coef []float64) BSpline {
-	// TODO:
-	// assert: order >= 1, knot.Count() > 0, knot.Count() + order == len(coef)
-	bsplines := buildBSplines(order, knot)
+// NewBSplineSimple B-Spline of the given order on the knots.
+// Requires order >= 0, knot.Count() >= 2 and len(coef) == knot.Count() + order.
+// If the last knot is repeated order+1 times, as in knot.NewClampedKnot,
+// the last basis function vanishes identically and len(coef) == knot.Count() + order - 1
+// is also accepted; the spline then interpolates coef[0] and coef[len(coef)-1].
+func NewBSplineSimple(order int, knot knot.Knot, coef []float64) (BSpline, error) {
+	if order < 0 {
+		return nil, fmt.Errorf("[BSpline] Negative order %d: %w", order, splineerr.ErrInvalidOrder)
+	}
+	if knot == nil || knot.Count() < 2 {
+		return nil, fmt.Errorf("[BSpline] Less than 2 knots: %w", splineerr.ErrInvalidKnots)
+	}
+	if !knot.IsSorted() {
+		return nil, fmt.Errorf("[BSpline] Knots are not sorted: %w", splineerr.ErrInvalidKnots)
+	}
+	if len(coef) != knot.Count()+order && !(isClamped(order, knot) && len(coef) == knot.Count()+order-1) {
+		return nil, fmt.Errorf("[BSpline] %d coefficients for %d basis functions: %w", len(coef), knot.Count()+order, splineerr.ErrCoefLength)
+	}
+	return newBSplineSimple(order, knot, coef), nil
+}
+
+// isClamped Whether the last knot is repeated order+1 times
+func isClamped(order int, knot knot.Knot) bool {
+	last := knot.Count() - 1
+	return knot.At(last) == knot.At(last+order)
+}
+
+// newBSplineSimple B-Spline without validation of the arguments
+func newBSplineSimple(order int, knot knot.Knot, coef []float64) *bSplineSimple {
+	// Basis B_j is supported on [t_j, t_(j+order+1)], j < len(coef);
+	// de Boor's algorithm reads up to order knots beyond either end of a span.
+	n := len(coef) + order
+	t := make([]float64, 0, n+2*order+1)
+	for i := -order; i <= n+order; i++ {
+		t = append(t, knot.At(i-order))
+	}
 	return &bSplineSimple{
-		knots:    knot,
-		order:    order,
-		bsplines: bsplines,
-		coefs:    coef,
+		knots: knot,
+		order: order,
+		coefs: coef,
+		t:     t,
 	}
 }
 
 func (b *bSplineSimple) At(x float64) float64 {
-	idx := b.knots.Index(x)
-	var v float64
-	for m := -b.order; m <= 0; m++ {
-		v += b.GetCoef(idx+m) * b.GetBSpline(idx+m).Evaluate(x)
+	return b.evaluate(x, 0, b.coefs, 0)
+}
+
+// EvalInto dst[i] = At(xs[i]). The knot span of each abscissa is searched
+// forward from that of the previous one, so sorted xs need no binary search.
+func (b *bSplineSimple) EvalInto(dst, xs []float64) {
+	if len(dst) < len(xs) {
+		panic(fmt.Sprintf("[BSpline] EvalInto: %d destinations for %d abscissae", len(dst), len(xs)))
+	}
+	s := -1
+	for i, x := range xs {
+		next, ok := b.spanFrom(x, s)
+		if !ok {
+			dst[i] = 0
+			continue
+		}
+		s = next
+		dst[i] = b.evaluateSpan(x, s, 0, b.coefs, 0)
+	}
+}
+
+// DerivativeAt k-th derivative at x
+func (b *bSplineSimple) DerivativeAt(x float64, k int) float64 {
+	return b.evaluate(x, k, b.coefs, 0)
+}
+
+// NonzeroBSplines Values of the basis functions which may be nonzero at x,
+// from the basis of index first.
+func (b *bSplineSimple) NonzeroBSplines(x float64) (int, []float64) {
+	s, ok := b.span(x)
+	if !ok {
+		return 0, nil
+	}
+	values := make([]float64, b.order+1)
+	b.basisFuns(s, x, values)
+
+	// Drop the basis functions which do not exist near the ends
+	first := s - b.order
+	if first < 0 {
+		values = values[-first:]
+		first = 0
+	}
+	if last := first + len(values); last > len(b.coefs) {
+		values = values[:len(values)-(last-len(b.coefs))]
+	}
+	return first, values
+}
+
+// Derivative k-th derivative as a B-Spline of order-k on the same knots, from
+//     d_j = q * (c_j - c_(j-1)) / (t_(j+q) - t_j)
+// applied k times, where q is the order being differentiated.
+// It equals DerivativeAt only on [k_0, k_(count-1)]: the differences at the ends of the padded support
+// belong to basis functions of order q-1 on [k_(-q), k_0] and [k_(count-1), k_(count+q)],
+// which do not exist on the same knots, and are dropped.
+func (b *bSplineSimple) Derivative(k int) (BSpline, error) {
+	if k < 0 || k > b.order {
+		return nil, fmt.Errorf("[BSpline] Derivative %d of order %d: %w", k, b.order, splineerr.ErrInvalidOrder)
+	}
+	coefs := make([]float64, len(b.coefs))
+	copy(coefs, b.coefs)
+	for q := b.order; q > b.order-k; q-- {
+		// t_i = knots.At(i - order) for this order q; the coefficient j of
+		// the derivative belongs to the basis starting at knot j - q + 1
+		diff := make([]float64, len(coefs)-1)
+		for j := range diff {
+			width := b.knots.At(j+1) - b.knots.At(j-q+1)
+			if width == 0 {
+				continue
+			}
+			diff[j] = float64(q) * (coefs[j+1] - coefs[j]) / width
+		}
+		coefs = diff
+	}
+	return newBSplineSimple(b.order-k, b.knots, coefs), nil
+}
+
+// Antiderivative B-Spline of order+1 on the same knots whose derivative is b, from
+//     e_j = e_(j-1) + c_j * (t_(j+order+1) - t_j) / (order + 1)
+// It equals Integral from the left end of the support of b only up to k_(count-1):
+// the basis function of order+1 which would hold the total e_n further right does not exist on the same knots,
+// so it falls back to 0 past the padded support. Use Integral for bounds beyond k_(count-1).
+func (b *bSplineSimple) Antiderivative() (BSpline, error) {
+	return b.antiderivative(), nil
+}
+
+func (b *bSplineSimple) antiderivative() *bSplineSimple {
+	coefs := make([]float64, len(b.coefs)+1)
+	for j, c := range b.coefs {
+		// B_j is supported on [k_(j-order), k_(j+1)]
+		width := b.knots.At(j+1) - b.knots.At(j-b.order)
+		coefs[j+1] = coefs[j] + c*width/float64(b.order+1)
+	}
+	return newBSplineSimple(b.order+1, b.knots, coefs)
+}
+
+// Integral Definite integral from "from" to "to", which may lie outside the knots
+func (b *bSplineSimple) Integral(from, to float64) float64 {
+	primitive := b.primitive()
+	return primitive(to) - primitive(from)
+}
+
+// primitive Integral of b from the left end of its support to x.
+// The antiderivative sum_j e_j * B_j(x) equals it left of knots.At(count) only, since the basis functions
+// of order+1 which would hold the total e_n further right do not exist on the same knots.
+// By the partition of unity it is written right of k_0 as
+//     e_n + sum_j (e_j - e_n) * B_j(x)
+// which stays at e_n past the support of b.
+func (b *bSplineSimple) primitive() func(x float64) float64 {
+	anti := b.antiderivative()
+	total := anti.coefs[len(anti.coefs)-1]
+	coefs := make([]float64, len(anti.coefs))
+	for j, e := range anti.coefs {
+		coefs[j] = e - total
+	}
+	remaining := newBSplineSimple(anti.order, anti.knots, coefs)
+	start := b.knots.At(0)
+	return func(x float64) float64 {
+		if x < start {
+			return anti.At(x)
+		}
+		return total + remaining.At(x)
 	}
-	return v
 }
 
 func (b *bSplineSimple) Knots() knot.Knot {
@@ -59,86 +211,37 @@ func (b *bSplineSimple) GetCoef(idx int) float64 {
 	return b.coefs[idx]
 }
 
-// GetBSpline find cached B-Spline function from index of the knot
+// GetBSpline B-Spline basis function of the index, supported on [k_(idx-order), k_(idx+1)]
 func (b *bSplineSimple) GetBSpline(idx int) BSplineFunc {
-	// idx += b.order
 	if idx < 0 {
-		return b.bsplines[0]
+		idx = 0
 	}
-	if idx >= len(b.bsplines) {
-		return b.bsplines[len(b.bsplines)-1]
+	if idx >= len(b.coefs) {
+		idx = len(b.coefs) - 1
 	}
-	return b.bsplines[idx]
+	return &bSplineBasis{spline: b, idx: idx}
 }
 
 ///////////////////////////////////////
 
 type BSplineFunc interface {
 	Evaluate(float64) float64
+	// Derivative k-th derivative at x
+	Derivative(x float64, k int) float64
 }
 
-type bSplineHaar struct {
-	knots [2]float64
-}
+// unitCoef Coefficient of a single basis function
+var unitCoef = []float64{1}
 
-func (b *bSplineHaar) Evaluate(x float64) float64 {
-	if b.knots[0] <= x && x < b.knots[1] {
-		return 1
-	}
-	return 0
+type bSplineBasis struct {
+	spline *bSplineSimple
+	idx    int
 }
 
-type bSplineOrder struct {
-	leftRamp   BSplineFunc
-	rightRamp  BSplineFunc
-	leftKnots  [2]float64 // ti, ti+o
-	rightKnots [2]float64 // ti+1, ti+o+1
-	order      int
-}
-
-func (b *bSplineOrder) Evaluate(x float64) float64 {
-	var y float64
-	if b.leftKnots[0] != b.leftKnots[1] {
-		y += ((x - b.leftKnots[0]) / (b.leftKnots[1] - b.leftKnots[0])) * b.leftRamp.Evaluate(x)
-	}
-	if b.rightKnots[0] != b.rightKnots[1] {
-		y += ((b.rightKnots[1] - x) / (b.rightKnots[1] - b.rightKnots[0])) * b.rightRamp.Evaluate(x)
-	}
-	return y
+func (b *bSplineBasis) Evaluate(x float64) float64 {
+	return b.spline.evaluate(x, 0, unitCoef, b.idx)
 }
 
-func newBSplineOrder(t1, t2 float64, t3, t4 float64, order int, lspline, rspline BSplineFunc) *bSplineOrder {
-	return &bSplineOrder{
-		leftRamp:   lspline,
-		rightRamp:  rspline,
-		order:      order,
-		leftKnots:  [2]float64{t1, t2},
-		rightKnots: [2]float64{t3, t4},
-	}
-}
-
-func buildBSplines(order int, knots knot.Knot) []BSplineFunc {
-	if order < 0 {
-		return nil
-	}
-	var splines []BSplineFunc
-	// Order 0
-	for idx := -order; idx < knots.Count()+order; idx++ {
-		k1, k2 := knots.At(idx), knots.At(idx+1)
-		splines = append(splines, &bSplineHaar{knots: [2]float64{k1, k2}})
-	}
-
-	// Order 1~ : Recursive
-	for m := 1; m <= order; m++ {
-		for idx := -order; idx < knots.Count()+order-m; idx++ {
-			a1, a2 := knots.At(idx), knots.At(idx+m)
-			t1, t2 := knots.At(idx+1), knots.At(idx+m+1)
-			fa := splines[idx+order]
-			ft := splines[idx+1+order]
-			fcn := newBSplineOrder(a1, a2, t1, t2, m, fa, ft)
-			splines[idx+order] = fcn
-		}
-	}
-	splines = splines[:knots.Count()+order]
-	return splines
+func (b *bSplineBasis) Derivative(x float64, k int) float64 {
+	return b.spline.evaluate(x, k, unitCoef, b.idx)
 }
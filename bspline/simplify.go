@@ -0,0 +1,200 @@
+package bspline
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/ppoly"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+)
+
+// Simplify B-Spline with as many interior knots removed as possible,
+// deviating from b by at most tol on [k_0, k_(count-1)], and the deviation.
+// Knots are removed one at a time by Tiller's algorithm; the deviation is the
+// exact maximum of |b - simplified|, found from the extrema of their piecewise polynomial difference.
+// Reference: Algorithm A5.8, L. Piegl and W. Tiller, The NURBS Book
+func (b *bSplineSimple) Simplify(tol float64) (BSpline, float64, error) {
+	if tol < 0 || math.IsNaN(tol) {
+		return nil, 0, fmt.Errorf("[BSpline] Tolerance %f: %w", tol, splineerr.ErrInvalidArgument)
+	}
+	current := newBSplineSimple(b.order, b.knots, append([]float64{}, b.coefs...))
+	var deviation float64
+	for removed := true; removed; {
+		removed = false
+		start, end := current.knots.At(0), current.knots.At(current.knots.Count()-1)
+		// r: index of the last copy of an interior knot in the knot vector t
+		for r := 1; r < len(current.coefs); r++ {
+			u := current.knot(r)
+			if u <= start || u >= end || u == current.knot(r+1) {
+				continue
+			}
+			s := 1
+			for current.knot(r-s) == u {
+				s++
+			}
+			candidate, err := current.removeKnot(r, s)
+			if err != nil {
+				return nil, 0, err
+			}
+			if d := maxDeviation(b, candidate); d <= tol {
+				current, deviation, removed = candidate, d, true
+				break
+			}
+		}
+	}
+	return current, deviation, nil
+}
+
+// removeKnot B-Spline with one copy of the knot t_r of multiplicity s removed
+func (b *bSplineSimple) removeKnot(r, s int) (*bSplineSimple, error) {
+	coef := func(j int) float64 {
+		if 0 <= j && j < len(b.coefs) {
+			return b.coefs[j]
+		}
+		return 0
+	}
+	modified, fout := tillerRemoval(b.order, b.knot, coef, r, s)
+	coefs := make([]float64, 0, len(b.coefs)-1)
+	for j := range b.coefs {
+		if j != fout {
+			coefs = append(coefs, modified(j))
+		}
+	}
+
+	sequence := make([]float64, 0, b.knots.Len()-1)
+	u := b.knot(r)
+	dropped := false
+	for i := -b.knots.Padding(); i < b.knots.Count()+b.knots.Padding(); i++ {
+		if k := b.knots.At(i); k == u && !dropped {
+			dropped = true
+		} else {
+			sequence = append(sequence, k)
+		}
+	}
+	knots, err := knot.NewSequenceKnot(b.knots.Padding(), sequence...)
+	if err != nil {
+		return nil, err
+	}
+	return newBSplineSimple(b.order, knots, coefs), nil
+}
+
+// tillerRemoval Coefficients after removing one copy of the knot u = t_r of multiplicity s,
+// solved from both ends of the affected coefficients P_first, ... , P_last.
+// The coefficient fout is to be deleted; the others are given by modified.
+func tillerRemoval(order int, t func(int) float64, coef func(int) float64, r, s int) (modified func(int) float64, fout int) {
+	p := order
+	u := t(r)
+	first, last := r-p, r-s
+	off := first - 1
+	temp := make([]float64, last-first+3)
+	temp[0] = coef(first - 1)
+	temp[last+1-off] = coef(last + 1)
+
+	i, j := first, last
+	ii, jj := 1, last-off
+	for j-i > 0 {
+		alfi := (u - t(i)) / (t(i+p+1) - t(i))
+		alfj := (u - t(j)) / (t(j+p+1) - t(j))
+		temp[ii] = (coef(i) - (1-alfi)*temp[ii-1]) / alfi
+		temp[jj] = (coef(j) - alfj*temp[jj+1]) / (1 - alfj)
+		i++
+		ii++
+		j--
+		jj--
+	}
+
+	override := make(map[int]float64)
+	i, j = first, last
+	for j-i > 0 {
+		override[i] = temp[i-off]
+		override[j] = temp[j-off]
+		i++
+		j--
+	}
+	modified = func(k int) float64 {
+		if v, ok := override[k]; ok {
+			return v
+		}
+		return coef(k)
+	}
+	return modified, (2*r - s - p) / 2
+}
+
+// maxDeviation Largest |f(x) - g(x)| on [k_0, k_(count-1)] of f
+func maxDeviation(f, g BSpline) float64 {
+	knots := f.Knots()
+	return maxAbs(f.ToPiecewisePolynomial().Sub(g.ToPiecewisePolynomial()), knots.At(0), knots.At(knots.Count()-1))
+}
+
+// maxAbs Largest |d(x)| on [a, b], at the ends, the breaks or the extrema of d
+func maxAbs(d *ppoly.PiecewisePolynomial, a, b float64) float64 {
+	_, lowest := d.Min(a, b)
+	_, highest := d.Max(a, b)
+	return math.Max(-lowest, highest)
+}
+
+// Simplify Periodic B-Spline with as many knots removed from every period as possible,
+// deviating from b by at most tol, and the deviation. See bSplineSimple.Simplify.
+func (b *bSplinePeriodic) Simplify(tol float64) (BSpline, float64, error) {
+	if tol < 0 || math.IsNaN(tol) {
+		return nil, 0, fmt.Errorf("[BSpline] Tolerance %f: %w", tol, splineerr.ErrInvalidArgument)
+	}
+	current := b
+	var deviation float64
+	for removed := true; removed; {
+		removed = false
+		knots := current.spline.knots
+		// Keep more knot spans than the order
+		if knots.Count()-2 <= current.spline.order {
+			break
+		}
+		for i := 1; i < knots.Count()-1; i++ {
+			candidate, err := current.removeKnot(i)
+			if err != nil {
+				return nil, 0, err
+			}
+			if d := maxDeviation(b, candidate); d <= tol {
+				current, deviation, removed = candidate, d, true
+				break
+			}
+		}
+	}
+	return current, deviation, nil
+}
+
+// removeKnot Periodic B-Spline with the knot k_idx removed from every period
+func (b *bSplinePeriodic) removeKnot(idx int) (*bSplinePeriodic, error) {
+	knots := b.spline.knots
+	p := b.spline.order
+	n := len(b.coefs)
+	coef := func(j int) float64 {
+		return b.coefs[(j%n+n)%n]
+	}
+	t := func(i int) float64 {
+		return knots.At(i - p)
+	}
+	modified, fout := tillerRemoval(p, t, coef, idx+p, 1)
+
+	// The same knot is removed from every period, so the modified coefficients are periodic;
+	// after deleting fout, take a period of them from fout on
+	periodic := func(k int) float64 {
+		return modified(idx + ((k-idx)%n+n)%n)
+	}
+	coefs := make([]float64, n-1)
+	for i := fout; i < fout+n-1; i++ {
+		coefs[((i%(n-1))+(n-1))%(n-1)] = periodic(i + 1)
+	}
+
+	breaks := make([]float64, 0, knots.Count()-1)
+	for i := 0; i < knots.Count(); i++ {
+		if i != idx {
+			breaks = append(breaks, knots.At(i))
+		}
+	}
+	reduced, err := knot.NewPeriodicKnot(knots.Padding(), breaks...)
+	if err != nil {
+		return nil, err
+	}
+	return newBSplinePeriodic(p, reduced, coefs), nil
+}
@@ -0,0 +1,91 @@
+package bspline
+
+import (
+	"fmt"
+
+	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+)
+
+// Surface Tensor-product B-Spline surface
+//     f(x, y) = sum_(i, j) c_ij * B_i(x) * C_j(y)
+// where B_i are the basis functions of one order on the knots in x,
+// and C_j those of another order on the knots in y.
+type Surface struct {
+	// basisX B-Spline in x whose basis functions are B_i. Its own coefficients are unused.
+	basisX *bSplineSimple
+	// rows B-Splines sum_j c_ij * C_j in y, so that f(x, y) = sum_i B_i(x) * rows[i](y)
+	rows []*bSplineSimple
+}
+
+// NewSurface Surface of the orders on the knots in x and y, with the coefficients coefs[i][j] = c_ij.
+// len(coefs) and the length of every row follow NewBSplineSimple for the knots in x and y respectively.
+func NewSurface(orderX int, knotsX knot.Knot, orderY int, knotsY knot.Knot, coefs [][]float64) (*Surface, error) {
+	basisX, err := NewBSplineSimple(orderX, knotsX, make([]float64, len(coefs)))
+	if err != nil {
+		return nil, err
+	}
+	rows := make([]*bSplineSimple, len(coefs))
+	for i, c := range coefs {
+		if len(c) != len(coefs[0]) {
+			return nil, fmt.Errorf("[BSpline] %d coefficients in the row %d, %d in the first: %w", len(c), i, len(coefs[0]), splineerr.ErrCoefLength)
+		}
+		row, err := NewBSplineSimple(orderY, knotsY, append([]float64{}, c...))
+		if err != nil {
+			return nil, err
+		}
+		rows[i] = row.(*bSplineSimple)
+	}
+	return &Surface{basisX: basisX.(*bSplineSimple), rows: rows}, nil
+}
+
+// Orders Orders in x and y
+func (s *Surface) Orders() (int, int) {
+	return s.basisX.order, s.rows[0].order
+}
+
+// Knots Knots in x and y
+func (s *Surface) Knots() (knot.Knot, knot.Knot) {
+	return s.basisX.knots, s.rows[0].knots
+}
+
+// CoefCount Number of coefficients in x and y
+func (s *Surface) CoefCount() (int, int) {
+	return len(s.rows), len(s.rows[0].coefs)
+}
+
+// GetCoef c_ij, or 0 if out of range
+func (s *Surface) GetCoef(i, j int) float64 {
+	if i < 0 || i >= len(s.rows) || j < 0 || j >= len(s.rows[i].coefs) {
+		return 0
+	}
+	return s.rows[i].coefs[j]
+}
+
+// SetCoef c_ij = v, ignored if out of range
+func (s *Surface) SetCoef(i, j int, v float64) {
+	if 0 <= i && i < len(s.rows) {
+		s.rows[i].SetCoef(j, v)
+	}
+}
+
+func (s *Surface) At(x, y float64) float64 {
+	return s.DerivativeAt(x, y, 0, 0)
+}
+
+// DerivativeAt Partial derivative of f, kx times in x and ky times in y, at (x, y).
+// Only the rows of the basis functions in x which may be nonzero at x are evaluated.
+func (s *Surface) DerivativeAt(x, y float64, kx, ky int) float64 {
+	first, values := s.basisX.NonzeroBSplines(x)
+	var v float64
+	for m, b := range values {
+		i := first + m
+		if kx > 0 {
+			b = s.basisX.GetBSpline(i).Derivative(x, kx)
+		}
+		if b != 0 {
+			v += b * s.rows[i].DerivativeAt(y, ky)
+		}
+	}
+	return v
+}
@@ -1,7 +1,13 @@
 package cubicSpline
 
 import (
+	"fmt"
+	"math"
+
 	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/ppoly"
+	"github.com/helloworldpark/gonaturalspline/selection"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
 	"gonum.org/v1/gonum/mat"
 )
 
@@ -16,26 +22,97 @@ type NaturalCubicSplines struct {
 	coefs   *mat.VecDense
 	lambda  float64
 
+	// x Abscissae of the observations. If nil, the observations are at the knots.
+	x []float64
+	// w Weights of the observations. If nil, all weights are 1.
+	w []float64
+
 	solverMatrix *mat.Dense
 }
 
-// NewNaturalCubicSplines A new pointer of NaturalCubicSpline struct
-func NewNaturalCubicSplines(knots knot.Knot, coefs []float64) *NaturalCubicSplines {
+// NewNaturalCubicSplines A new pointer of NaturalCubicSpline struct.
+// Requires knots.Count() >= 2, and coefs either nil or of length knots.Count().
+func NewNaturalCubicSplines(knots knot.Knot, coefs []float64) (*NaturalCubicSplines, error) {
+	if knots == nil || knots.Count() < 2 {
+		return nil, fmt.Errorf("[NaturalCubicSpline] Less than 2 knots: %w", splineerr.ErrInvalidKnots)
+	}
+	for i := 1; i < knots.Count(); i++ {
+		if knots.At(i-1) >= knots.At(i) {
+			return nil, fmt.Errorf("[NaturalCubicSpline] Knots are not strictly increasing: %w", splineerr.ErrInvalidKnots)
+		}
+	}
+	if coefs != nil && len(coefs) != knots.Count() {
+		return nil, fmt.Errorf("[NaturalCubicSpline] %d coefficients for %d knots: %w", len(coefs), knots.Count(), splineerr.ErrCoefLength)
+	}
 	return &NaturalCubicSplines{
 		splines: buildNaturalCubicSplines(knots),
 		knots:   knots,
 		coefs:   mat.NewVecDense(knots.Count(), coefs),
+	}, nil
+}
+
+// NewNaturalCubicSplinesFromData A new pointer of NaturalCubicSpline struct
+// whose knots are the unique values of x, with x as the abscissae of the observations.
+func NewNaturalCubicSplinesFromData(x []float64) (*NaturalCubicSplines, error) {
+	knots, err := knot.NewArbitraryKnotBuilder(0, x...).Build()
+	if err != nil {
+		return nil, err
+	}
+	ncs, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		return nil, err
+	}
+	ncs.SetAbscissae(x)
+	return ncs, nil
+}
+
+// SetAbscissae Set the abscissae of the observations used by Solve, SolveDF and SelectLambda.
+// x may be unsorted and may have repeated values. If nil, the observations are at the knots.
+func (ncs *NaturalCubicSplines) SetAbscissae(x []float64) {
+	ncs.x = x
+	ncs.solverMatrix = nil
+}
+
+// SetWeights Set the weights of the observations used by Solve, SolveDF and SelectLambda.
+// The fit minimizes sum_i w_i * (y_i - f(x_i))^2 + lambda * integral of f''(x)^2 dx.
+// A weight of 0 masks the observation. If nil, all weights are 1.
+func (ncs *NaturalCubicSplines) SetWeights(w []float64) error {
+	for _, v := range w {
+		if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("[NaturalCubicSpline] Weight %f: %w", v, splineerr.ErrInvalidWeights)
+		}
+	}
+	ncs.w = w
+	ncs.solverMatrix = nil
+	return nil
+}
+
+// Fit Fit the smoothing spline to the observations (x_i, y_i) with the current lambda
+func (ncs *NaturalCubicSplines) Fit(x, y []float64) error {
+	if len(x) != len(y) {
+		return fmt.Errorf("[NaturalCubicSpline] %d abscissae, %d observations: %w", len(x), len(y), splineerr.ErrDataLength)
+	}
+	ncs.SetAbscissae(x)
+	if err := ncs.Solve(ncs.lambda); err != nil {
+		return err
 	}
+	return ncs.Interpolate(y)
 }
 
 // Solve Solve the matrix needed when calculating smoothing spline.
-func (ncs *NaturalCubicSplines) Solve(lambda float64) {
-	ncs.lambda = lambda
+func (ncs *NaturalCubicSplines) Solve(lambda float64) error {
+	if lambda < 0 {
+		return fmt.Errorf("[NaturalCubicSpline] Negative lambda %f: %w", lambda, splineerr.ErrInvalidArgument)
+	}
 	N := ncs.calcBasisMatrix()
 	S := ncs.calcSmoothMatrix()
+	WN, err := ncs.calcWeightedBasisMatrix(N)
+	if err != nil {
+		return err
+	}
 
 	var NtN mat.Dense
-	NtN.Mul(N.T(), N)
+	NtN.Mul(N.T(), WN)
 
 	S.Scale(lambda, S)
 
@@ -47,24 +124,80 @@ func (ncs *NaturalCubicSplines) Solve(lambda float64) {
 
 	var chol mat.Cholesky
 	if ok := chol.Factorize(NtNSym); !ok {
-		panic(">>>>>>>>>>")
+		return &splineerr.SingularSystemError{Cond: mat.Cond(NtNSym, 1)}
 	}
 
 	var cholInv mat.SymDense
-	chol.InverseTo(&cholInv)
+	if err := chol.InverseTo(&cholInv); err != nil {
+		if _, ok := err.(mat.Condition); !ok {
+			return &splineerr.SingularSystemError{Cond: chol.Cond()}
+		}
+	}
 
 	var all mat.Dense
-	all.Mul(&cholInv, N.T())
+	all.Mul(&cholInv, WN.T())
 
+	ncs.lambda = lambda
 	ncs.solverMatrix = &all
+	return nil
 }
 
-// Interpolate Calculate the coefficients interpolating y
-func (ncs *NaturalCubicSplines) Interpolate(y []float64) {
+// Interpolate Calculate the coefficients interpolating y.
+// y are the observations at the abscissae, or at the knots if none were set.
+func (ncs *NaturalCubicSplines) Interpolate(y []float64) error {
+	if ncs.solverMatrix == nil {
+		return fmt.Errorf("[NaturalCubicSpline] Call Solve before Interpolate: %w", splineerr.ErrNotFitted)
+	}
+	if _, c := ncs.solverMatrix.Dims(); c != len(y) {
+		return fmt.Errorf("[NaturalCubicSpline] %d abscissae, %d observations: %w", c, len(y), splineerr.ErrDataLength)
+	}
 	Y := mat.NewVecDense(len(y), y)
 	var coefs mat.VecDense
 	coefs.MulVec(ncs.solverMatrix, Y)
 	ncs.coefs = &coefs
+	return nil
+}
+
+// SolveDF Solve with lambda whose smoother matrix has trace df, and return the lambda.
+// df should be in [2, knots.Count()].
+func (ncs *NaturalCubicSplines) SolveDF(df float64) (float64, error) {
+	pls, err := ncs.penalizedLeastSquares(nil)
+	if err != nil {
+		return 0, err
+	}
+	lambda, _, err := selection.LambdaForDF(pls, df)
+	if err != nil {
+		return 0, err
+	}
+	return lambda, ncs.Solve(lambda)
+}
+
+// SelectLambda Choose lambda minimizing the criterion for the observations y at the abscissae,
+// then solve and interpolate with it.
+func (ncs *NaturalCubicSplines) SelectLambda(y []float64, criterion selection.Criterion) (selection.Result, error) {
+	if n := len(ncs.abscissae()); n != len(y) {
+		return selection.Result{}, fmt.Errorf("[NaturalCubicSpline] %d abscissae, %d observations: %w", n, len(y), splineerr.ErrDataLength)
+	}
+	pls, err := ncs.penalizedLeastSquares(y)
+	if err != nil {
+		return selection.Result{}, err
+	}
+	result, err := selection.Minimize(pls, criterion)
+	if err != nil {
+		return selection.Result{}, err
+	}
+	if err := ncs.Solve(result.Lambda); err != nil {
+		return selection.Result{}, err
+	}
+	return result, ncs.Interpolate(y)
+}
+
+func (ncs *NaturalCubicSplines) penalizedLeastSquares(y []float64) (*selection.PenalizedLeastSquares, error) {
+	if ncs.w != nil && len(ncs.w) != len(ncs.abscissae()) {
+		return nil, fmt.Errorf("[NaturalCubicSpline] %d abscissae, %d weights: %w", len(ncs.abscissae()), len(ncs.w), splineerr.ErrDataLength)
+	}
+	n := len(ncs.splines)
+	return selection.NewPenalizedLeastSquares(ncs.calcBasisMatrix(), ncs.calcSmoothMatrix(), n-2, y, ncs.w), nil
 }
 
 // At Calculate the smoothing spline at x
@@ -76,11 +209,204 @@ func (ncs *NaturalCubicSplines) At(x float64) float64 {
 	return y
 }
 
+// EvalInto dst[i] = At(xs[i]). Panics if len(dst) < len(xs).
+// The spline is expanded once into cubics on the knot spans, which are
+// walked forward for sorted xs instead of summing every basis function.
+func (ncs *NaturalCubicSplines) EvalInto(dst, xs []float64) {
+	if len(dst) < len(xs) {
+		panic(fmt.Sprintf("[NaturalCubicSpline] EvalInto: %d destinations for %d abscissae", len(dst), len(xs)))
+	}
+	count := ncs.knots.Count()
+	w := ncs.truncatedPowerWeights()
+	c0, c1 := ncs.coefs.AtVec(0), ncs.coefs.AtVec(1)
+
+	// On [k_i, k_(i+1)) the spline is a0 + a1 * t + a2 * t^2 + a3 * t^3, t = x - k_i
+	var i int
+	var a0, a1, a2, a3 float64
+	reset := func() {
+		i = 0
+		a0, a1, a2, a3 = c0+c1*ncs.knots.At(0), c1, 0, w[0]
+	}
+	reset()
+	for j, x := range xs {
+		if x < ncs.knots.At(0) {
+			dst[j] = c0 + c1*x
+			continue
+		}
+		if x < ncs.knots.At(i) {
+			reset()
+		}
+		for i < count-1 && ncs.knots.At(i+1) <= x {
+			// Taylor expansion at the next knot, then add its truncated power
+			h := ncs.knots.At(i+1) - ncs.knots.At(i)
+			a0, a1, a2 = a0+h*(a1+h*(a2+h*a3)), a1+h*(2*a2+3*h*a3), a2+3*h*a3
+			i++
+			a3 += w[i]
+		}
+		t := x - ncs.knots.At(i)
+		dst[j] = a0 + t*(a1+t*(a2+t*a3))
+	}
+}
+
+// ToPiecewisePolynomial Cubic pieces of the spline on the knot spans of [k_0, k_(count-1)].
+// Beyond the boundary knots the spline is linear, whereas the pieces are extrapolated as cubics.
+func (ncs *NaturalCubicSplines) ToPiecewisePolynomial() *ppoly.PiecewisePolynomial {
+	count := ncs.knots.Count()
+	w := ncs.truncatedPowerWeights()
+	c0, c1 := ncs.coefs.AtVec(0), ncs.coefs.AtVec(1)
+
+	breaks := make([]float64, count)
+	coefs := make([][]float64, count-1)
+	a0, a1, a2, a3 := c0+c1*ncs.knots.At(0), c1, 0.0, w[0]
+	for i := range coefs {
+		breaks[i] = ncs.knots.At(i)
+		coefs[i] = []float64{a0, a1, a2, a3}
+		h := ncs.knots.At(i+1) - ncs.knots.At(i)
+		a0, a1, a2 = a0+h*(a1+h*(a2+h*a3)), a1+h*(2*a2+3*h*a3), a2+3*h*a3
+		a3 += w[i+1]
+	}
+	breaks[count-1] = ncs.knots.At(count - 1)
+	return &ppoly.PiecewisePolynomial{Breaks: breaks, Coefs: coefs}
+}
+
+// Roots Abscissae where the spline equals level, in increasing order, isolated on its cubic pieces
+// and on the lines beyond the boundary knots. See ppoly.PiecewisePolynomial.Roots.
+func (ncs *NaturalCubicSplines) Roots(level float64) []float64 {
+	pp := ncs.ToPiecewisePolynomial()
+	start, end := pp.Breaks[0], pp.Breaks[len(pp.Breaks)-1]
+
+	var roots []float64
+	if slope := pp.Coefs[0][1]; slope != 0 {
+		if x := start + (level-pp.Coefs[0][0])/slope; x < start {
+			roots = append(roots, x)
+		}
+	}
+	roots = append(roots, pp.Roots(level)...)
+	if slope := pp.Derivative().At(end); slope != 0 {
+		if x := end + (level-pp.At(end))/slope; x > end {
+			roots = append(roots, x)
+		}
+	}
+	return roots
+}
+
+// Extrema Strict local minima and maxima of the spline, in increasing order.
+// The spline is linear beyond the boundary knots, so they all lie in [k_0, k_(count-1)].
+// See ppoly.PiecewisePolynomial.Extrema.
+func (ncs *NaturalCubicSplines) Extrema() []ppoly.Extremum {
+	return ncs.ToPiecewisePolynomial().Extrema()
+}
+
+// InflectionPoints Abscissae where the second derivative of the spline changes sign, in increasing order
+func (ncs *NaturalCubicSplines) InflectionPoints() []float64 {
+	return ncs.ToPiecewisePolynomial().InflectionPoints()
+}
+
+// Min Smallest value of the spline on [a, b] and where it is attained
+func (ncs *NaturalCubicSplines) Min(a, b float64) (float64, float64) {
+	return ncs.extreme(a, b, false)
+}
+
+// Max Largest value of the spline on [a, b] and where it is attained
+func (ncs *NaturalCubicSplines) Max(a, b float64) (float64, float64) {
+	return ncs.extreme(a, b, true)
+}
+
+// extreme Smallest or largest value of the spline on [a, b], searched on the cubic pieces within,
+// and at a and b for the lines beyond the boundary knots
+func (ncs *NaturalCubicSplines) extreme(a, b float64, largest bool) (float64, float64) {
+	pp := ncs.ToPiecewisePolynomial()
+	better, search := func(v, best float64) bool { return v < best }, pp.Min
+	if largest {
+		better, search = func(v, best float64) bool { return v > best }, pp.Max
+	}
+	if a > b {
+		a, b = b, a
+	}
+	x, value := a, ncs.At(a)
+	if v := ncs.At(b); better(v, value) {
+		x, value = b, v
+	}
+	lo := math.Max(a, pp.Breaks[0])
+	hi := math.Min(b, pp.Breaks[len(pp.Breaks)-1])
+	if lo > hi {
+		return x, value
+	}
+	if u, v := search(lo, hi); better(v, value) {
+		x, value = u, v
+	}
+	return x, value
+}
+
+// truncatedPowerWeights w_k such that the spline is
+//     c_0 + c_1 * x + sum_k w_k * (x - k_k)_+^3
+func (ncs *NaturalCubicSplines) truncatedPowerWeights() []float64 {
+	count := ncs.knots.Count()
+	w := make([]float64, count)
+	if count < 3 {
+		return w
+	}
+	knotEnd := ncs.knots.At(count - 1)
+	knotLastToSecond := ncs.knots.At(count - 2)
+	for k := 0; k < count-2; k++ {
+		c := ncs.coefs.AtVec(k + 2)
+		v := c / (knotEnd - ncs.knots.At(k))
+		d := c / (knotEnd - knotLastToSecond)
+		w[k] += v
+		w[count-1] -= v
+		w[count-2] -= d
+		w[count-1] += d
+	}
+	return w
+}
+
+// Antiderivative Antiderivative of the smoothing spline, vanishing at the first knot
+func (ncs *NaturalCubicSplines) Antiderivative() CubicSpline {
+	primitives := buildNaturalCubicSplinePrimitives(ncs.knots)
+	coefs := make([]float64, len(primitives))
+	start := ncs.knots.At(0)
+	var offset float64
+	for i := range primitives {
+		coefs[i] = ncs.coefs.AtVec(i)
+		offset += coefs[i] * primitives[i](start)
+	}
+	return func(x float64) float64 {
+		y := -offset
+		for i := range primitives {
+			y += coefs[i] * primitives[i](x)
+		}
+		return y
+	}
+}
+
+// Integral Definite integral of the smoothing spline from a to b
+func (ncs *NaturalCubicSplines) Integral(a, b float64) float64 {
+	primitives := buildNaturalCubicSplinePrimitives(ncs.knots)
+	var y float64
+	for i := range primitives {
+		y += ncs.coefs.AtVec(i) * (primitives[i](b) - primitives[i](a))
+	}
+	return y
+}
+
+// abscissae Abscissae of the observations
+func (ncs *NaturalCubicSplines) abscissae() []float64 {
+	if ncs.x != nil {
+		return ncs.x
+	}
+	x := make([]float64, ncs.knots.Count())
+	for i := range x {
+		x[i] = ncs.knots.At(i)
+	}
+	return x
+}
+
+// calcBasisMatrix N_ij = N_j(x_i)
 func (ncs *NaturalCubicSplines) calcBasisMatrix() *mat.Dense {
 	n := len(ncs.splines)
-	m := mat.NewDense(ncs.knots.Count(), n, nil)
-	for i := 0; i < ncs.knots.Count(); i++ {
-		x := ncs.knots.At(i)
+	abscissae := ncs.abscissae()
+	m := mat.NewDense(len(abscissae), n, nil)
+	for i, x := range abscissae {
 		for j := 0; j < n; j++ {
 			v := ncs.splines[j](x)
 			m.Set(i, j, v)
@@ -89,24 +415,42 @@ func (ncs *NaturalCubicSplines) calcBasisMatrix() *mat.Dense {
 	return m
 }
 
+// calcWeightedBasisMatrix W * N
+func (ncs *NaturalCubicSplines) calcWeightedBasisMatrix(N *mat.Dense) (*mat.Dense, error) {
+	if ncs.w == nil {
+		return N, nil
+	}
+	if r, _ := N.Dims(); r != len(ncs.w) {
+		return nil, fmt.Errorf("[NaturalCubicSpline] %d abscissae, %d weights: %w", r, len(ncs.w), splineerr.ErrDataLength)
+	}
+	WN := mat.DenseCopyOf(N)
+	WN.Apply(func(i, j int, v float64) float64 {
+		return ncs.w[i] * v
+	}, WN)
+	return WN, nil
+}
+
+// calcSmoothMatrix Omega_jk = integral of N_j''(x) * N_k''(x) dx
+// Since N_j'' is piecewise linear, Simpson's rule on each knot span is exact.
 func (ncs *NaturalCubicSplines) calcSmoothMatrix() *mat.Dense {
 	n := len(ncs.splines)
-	p := mat.NewDense(ncs.knots.Count(), n, nil)
+	p := mat.NewDense(n, n, nil)
+	second := buildNaturalCubicSplineSecondDerivatives(ncs.knots)
 
-	knotEnd := ncs.knots.At(ncs.knots.Count() - 1)
-	knotEndEnd := ncs.knots.At(ncs.knots.Count() - 2)
-	for j := 2; j < n; j++ {
-		v := 12.0 * (knotEnd - ncs.knots.At(j-2))
-		p.Set(j, j, v)
-	}
-	for j := 2; j < n; j++ {
-		knotJ := ncs.knots.At(j - 2)
-		diffJ := knotEndEnd - knotJ
-		for m := j + 1; m < n; m++ {
-			knotM := ncs.knots.At(m - 2)
-			diffM := knotEndEnd - knotM
-			v := 12.0*(knotEnd-knotEndEnd) + 6.0*(diffM/diffJ)*(2*knotEndEnd-3*knotM+knotJ)
-			p.Set(j, m, v)
+	left := make([]float64, n)
+	mid := make([]float64, n)
+	right := make([]float64, n)
+	for i := 0; i < ncs.knots.Count()-1; i++ {
+		a, b := ncs.knots.At(i), ncs.knots.At(i+1)
+		c := (a + b) / 2
+		for j := 2; j < n; j++ {
+			left[j], mid[j], right[j] = second[j](a), second[j](c), second[j](b)
+		}
+		for j := 2; j < n; j++ {
+			for m := j; m < n; m++ {
+				v := (b - a) / 6 * (left[j]*left[m] + 4*mid[j]*mid[m] + right[j]*right[m])
+				p.Set(j, m, p.At(j, m)+v)
+			}
 		}
 	}
 	for j := 2; j < n; j++ {
@@ -149,3 +493,70 @@ func buildNaturalCubicSplines(knots knot.Knot) []CubicSpline {
 	}
 	return splines
 }
+
+// piecewiseCubicSecond Second derivative of piecewiseCubic(k)
+func piecewiseCubicSecond(k float64) CubicSpline {
+	return func(x float64) float64 {
+		if x < k {
+			return 0.0
+		}
+		return 6 * (x - k)
+	}
+}
+
+func buildNaturalCubicSplineSecondDerivatives(knots knot.Knot) []CubicSpline {
+	splines := make([]CubicSpline, knots.Count())
+	splines[0] = func(float64) float64 { return 0 }
+	splines[1] = func(float64) float64 { return 0 }
+
+	knotEnd := knots.At(knots.Count() - 1)
+	pEnd := piecewiseCubicSecond(knotEnd)
+	dEnd := func(x float64) float64 {
+		knotLastToSecond := knots.At(knots.Count() - 2)
+		p := piecewiseCubicSecond(knotLastToSecond)
+		return (p(x) - pEnd(x)) / (knotEnd - knotLastToSecond)
+	}
+
+	for k := 0; k < knots.Count()-2; k++ {
+		l := knots.At(k)
+		splines[k+2] = func(x float64) float64 {
+			p := piecewiseCubicSecond(l)
+			return (p(x)-pEnd(x))/(knotEnd-l) - dEnd(x)
+		}
+	}
+	return splines
+}
+
+// piecewiseCubicPrimitive Antiderivative of piecewiseCubic(k)
+func piecewiseCubicPrimitive(k float64) CubicSpline {
+	return func(x float64) float64 {
+		if x < k {
+			return 0.0
+		}
+		t := x - k
+		return t * t * t * t / 4
+	}
+}
+
+func buildNaturalCubicSplinePrimitives(knots knot.Knot) []CubicSpline {
+	splines := make([]CubicSpline, knots.Count())
+	splines[0] = func(x float64) float64 { return x }
+	splines[1] = func(x float64) float64 { return x * x / 2 }
+
+	knotEnd := knots.At(knots.Count() - 1)
+	pEnd := piecewiseCubicPrimitive(knotEnd)
+	dEnd := func(x float64) float64 {
+		knotLastToSecond := knots.At(knots.Count() - 2)
+		p := piecewiseCubicPrimitive(knotLastToSecond)
+		return (p(x) - pEnd(x)) / (knotEnd - knotLastToSecond)
+	}
+
+	for k := 0; k < knots.Count()-2; k++ {
+		l := knots.At(k)
+		splines[k+2] = func(x float64) float64 {
+			p := piecewiseCubicPrimitive(l)
+			return (p(x)-pEnd(x))/(knotEnd-l) - dEnd(x)
+		}
+	}
+	return splines
+}
@@ -1,12 +1,21 @@
 package cubicSpline
 
 import (
+	"errors"
 	"fmt"
 	"image/color"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 
 	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/ppoly"
+	"github.com/helloworldpark/gonaturalspline/selection"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+	"gonum.org/v1/gonum/mat"
 	"gonum.org/v1/plot"
 	"gonum.org/v1/plot/plotter"
 	"gonum.org/v1/plot/vg"
@@ -15,12 +24,22 @@ import (
 func TestNaturalCubicSpline(t *testing.T) {
 	const order = 3
 	const lambda = 0.001
-	knots := knot.NewUniformKnot(0, 10, 11, order)
+	knots, err := knot.NewUniformKnot(0, 10, 11, order)
+	if err != nil {
+		t.Fatal(err)
+	}
 	y := []float64{5, 8, 10, 8.5, 4, 0, -3.7, -5, 3.5, -2, 0}
 
-	ncs := NewNaturalCubicSplines(knots, nil)
-	ncs.Solve(lambda)
-	ncs.Interpolate(y)
+	ncs, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Solve(lambda); err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Interpolate(y); err != nil {
+		t.Fatal(err)
+	}
 
 	// // Using Solve function
 	// var rhs mat.VecDense
@@ -70,7 +89,478 @@ func TestNaturalCubicSpline(t *testing.T) {
 	p.Y.Max = +12
 
 	// Save the plot to a PNG file.
-	if err := p.Save(6*vg.Inch, 4*vg.Inch, "/Users/shp/Documents/projects/gonaturalspline/bspline/TestNaturalCubicSpline.png"); err != nil {
+	if err := p.Save(6*vg.Inch, 4*vg.Inch, filepath.Join(os.TempDir(), "TestNaturalCubicSpline.png")); err != nil {
 		panic(err)
 	}
 }
+
+func TestNaturalCubicSplineSmoothMatrix(t *testing.T) {
+	knots, err := knot.NewUniformKnot(0, 10, 6, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ncs, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	omega := ncs.calcSmoothMatrix()
+	second := buildNaturalCubicSplineSecondDerivatives(knots)
+
+	// Midpoint rule on a fine grid
+	const steps = 100000
+	h := 10.0 / steps
+	for j := range second {
+		for m := range second {
+			var v float64
+			for i := 0; i < steps; i++ {
+				x := (float64(i) + 0.5) * h
+				v += h * second[j](x) * second[m](x)
+			}
+			if math.Abs(v-omega.At(j, m)) > 1e-3 {
+				t.Fatalf("[NaturalCubicSpline] Omega[%d][%d] = %f, expected %f", j, m, omega.At(j, m), v)
+			}
+		}
+	}
+
+	// The penalty c^T * Omega * c is the integral of the squared second derivative
+	c := mat.NewVecDense(len(second), []float64{1, -2, 0.5, 3, -1, 2})
+	var integral float64
+	for i := 0; i < steps; i++ {
+		x := (float64(i) + 0.5) * h
+		var f float64
+		for j := range second {
+			f += c.AtVec(j) * second[j](x)
+		}
+		integral += h * f * f
+	}
+	if penalty := mat.Inner(c, omega, c); math.Abs(penalty-integral) > 1e-3*integral {
+		t.Fatalf("[NaturalCubicSpline] Penalty %f, integral %f", penalty, integral)
+	}
+}
+
+func TestNaturalCubicSplineSelectLambda(t *testing.T) {
+	knots, err := knot.NewUniformKnot(0, 10, 41, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rnd := rand.New(rand.NewSource(1))
+	y := make([]float64, knots.Count())
+	for i := range y {
+		y[i] = math.Sin(knots.At(i)) + 0.3*rnd.NormFloat64()
+	}
+	ncs, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := ncs.SelectLambda(y, selection.GCV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("[NaturalCubicSpline] lambda = %g, df = %f", result.Lambda, result.Fit.DF())
+	if result.Fit.DF() <= 2 || result.Fit.DF() >= float64(knots.Count())/2 {
+		t.Fatalf("[NaturalCubicSpline] Effective degrees of freedom %f", result.Fit.DF())
+	}
+	for i := range y {
+		if d := math.Abs(ncs.At(knots.At(i)) - y[i] + result.Fit.Residuals[i]); d > 1e-6 {
+			t.Fatalf("[NaturalCubicSpline] Refitted value differs at %d: %g", i, d)
+		}
+	}
+}
+
+func TestNaturalCubicSplineSolveDF(t *testing.T) {
+	knots, err := knot.NewUniformKnot(0, 10, 21, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ncs, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lambda, err := ncs.SolveDF(5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var trace float64
+	N := ncs.calcBasisMatrix()
+	for i := 0; i < knots.Count(); i++ {
+		for j := 0; j < knots.Count(); j++ {
+			trace += N.At(i, j) * ncs.solverMatrix.At(j, i)
+		}
+	}
+	if math.Abs(trace-5) > 1e-4 {
+		t.Fatalf("[NaturalCubicSpline] Trace of smoother matrix = %f at lambda %g, expected 5", trace, lambda)
+	}
+
+	// The ends of [2, knots.Count()] are the line and the interpolant
+	for _, count := range []int{21, 2} {
+		knots, err := knot.NewUniformKnot(0, 10, count, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ncs, err := NewNaturalCubicSplines(knots, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, df := range []float64{2, float64(count)} {
+			lambda, err := ncs.SolveDF(df)
+			if err != nil {
+				t.Fatalf("[NaturalCubicSpline] SolveDF(%f) on %d knots: %v", df, count, err)
+			}
+			var trace float64
+			N := ncs.calcBasisMatrix()
+			for i := 0; i < count; i++ {
+				for j := 0; j < count; j++ {
+					trace += N.At(i, j) * ncs.solverMatrix.At(j, i)
+				}
+			}
+			if math.Abs(trace-df) > 1e-3 {
+				t.Fatalf("[NaturalCubicSpline] Trace of smoother matrix = %f at lambda %g, expected %f", trace, lambda, df)
+			}
+		}
+	}
+}
+
+func TestNaturalCubicSplineFitData(t *testing.T) {
+	rnd := rand.New(rand.NewSource(3))
+	x := make([]float64, 300)
+	y := make([]float64, len(x))
+	for i := range x {
+		// Unsorted, with repeats
+		x[i] = math.Floor(rnd.Float64()*1000) / 100
+		y[i] = math.Sin(x[i]) + 0.1*rnd.NormFloat64()
+	}
+
+	knots, err := knot.NewUniformKnot(0, 10, 15, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ncs, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Solve(0.01); err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+	for v := 0.5; v <= 9.5; v += 0.1 {
+		if d := math.Abs(ncs.At(v) - math.Sin(v)); d > 0.1 {
+			t.Fatalf("[NaturalCubicSpline] |f(%f) - sin(%f)| = %f", v, v, d)
+		}
+	}
+}
+
+func TestNaturalCubicSplineFromData(t *testing.T) {
+	x := []float64{3, 1, 0, 1, 4, 3, 2}
+	y := []float64{2, 1, 0, 3, -1, 4, 5}
+	ncs, err := NewNaturalCubicSplinesFromData(x)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Solve(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Interpolate(y); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without smoothing, repeated abscissae are fitted by their mean
+	expected := map[float64]float64{0: 0, 1: 2, 2: 5, 3: 3, 4: -1}
+	for v, e := range expected {
+		if d := math.Abs(ncs.At(v) - e); d > 1e-8 {
+			t.Fatalf("[NaturalCubicSpline] f(%f) = %f, expected %f", v, ncs.At(v), e)
+		}
+	}
+}
+
+func TestNaturalCubicSplineWeights(t *testing.T) {
+	x := []float64{0, 1, 2, 3, 4, 5}
+	y := []float64{1, 3, 2, 5, 4, 6}
+	w := []float64{1, 3, 1, 2, 1, 1}
+
+	// Observations repeated as many times as their weights
+	var xr, yr []float64
+	for i := range x {
+		for k := 0; k < int(w[i]); k++ {
+			xr = append(xr, x[i])
+			yr = append(yr, y[i])
+		}
+	}
+
+	knots, err := knot.NewUniformKnot(0, 5, 6, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	weighted, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := weighted.SetWeights(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := weighted.Solve(0.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := weighted.Interpolate(y); err != nil {
+		t.Fatal(err)
+	}
+
+	repeated, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repeated.Solve(0.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := repeated.Fit(xr, yr); err != nil {
+		t.Fatal(err)
+	}
+
+	for v := 0.0; v <= 5; v += 0.05 {
+		if d := math.Abs(weighted.At(v) - repeated.At(v)); d > 1e-8 {
+			t.Fatalf("[NaturalCubicSpline] Weighted and repeated fits differ at %f: %g", v, d)
+		}
+	}
+}
+
+func TestNaturalCubicSplineErrors(t *testing.T) {
+	knots, err := knot.NewUniformKnot(0, 5, 6, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewNaturalCubicSplines(knots, make([]float64, 5)); !errors.Is(err, splineerr.ErrCoefLength) {
+		t.Fatalf("[NaturalCubicSpline] Expected ErrCoefLength, got %v", err)
+	}
+	ncs, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Interpolate(make([]float64, 6)); !errors.Is(err, splineerr.ErrNotFitted) {
+		t.Fatalf("[NaturalCubicSpline] Expected ErrNotFitted, got %v", err)
+	}
+	if err := ncs.Fit([]float64{1, 2}, []float64{1}); !errors.Is(err, splineerr.ErrDataLength) {
+		t.Fatalf("[NaturalCubicSpline] Expected ErrDataLength, got %v", err)
+	}
+	if err := ncs.SetWeights([]float64{1, -1}); !errors.Is(err, splineerr.ErrInvalidWeights) {
+		t.Fatalf("[NaturalCubicSpline] Expected ErrInvalidWeights, got %v", err)
+	}
+
+	// Without smoothing, 6 basis functions cannot be determined by 2 distinct abscissae
+	err = ncs.Fit([]float64{1, 1, 2, 2}, []float64{1, 2, 3, 4})
+	var singular *splineerr.SingularSystemError
+	if !errors.Is(err, splineerr.ErrSingularSystem) || !errors.As(err, &singular) {
+		t.Fatalf("[NaturalCubicSpline] Expected SingularSystemError, got %v", err)
+	}
+	t.Logf("[NaturalCubicSpline] %v", singular)
+}
+
+func TestNaturalCubicSplineIntegral(t *testing.T) {
+	knots, err := knot.NewUniformKnot(0, 10, 11, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := []float64{5, 8, 10, 8.5, 4, 0, -3.7, -5, 3.5, -2, 0}
+	ncs, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Solve(0.1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Interpolate(y); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simpson's rule on a fine grid, extending past the boundary knot where the spline is linear
+	const steps = 20000
+	a, b := -1.3, 11.7
+	h := (b - a) / steps
+	numeric := ncs.At(a) + ncs.At(b)
+	for i := 1; i < steps; i++ {
+		w := 2.0
+		if i%2 == 1 {
+			w = 4
+		}
+		numeric += w * ncs.At(a+float64(i)*h)
+	}
+	numeric *= h / 3
+	if d := math.Abs(ncs.Integral(a, b) - numeric); d > 1e-6 {
+		t.Fatalf("[NaturalCubicSpline] Integral = %f, numeric %f", ncs.Integral(a, b), numeric)
+	}
+
+	anti := ncs.Antiderivative()
+	if v := anti(knots.At(0)); v != 0 {
+		t.Fatalf("[NaturalCubicSpline] Antiderivative at the first knot = %g", v)
+	}
+	if d := math.Abs(anti(b) - anti(a) - ncs.Integral(a, b)); d > 1e-9 {
+		t.Fatalf("[NaturalCubicSpline] Antiderivative and Integral differ by %g", d)
+	}
+}
+
+func TestNaturalCubicSplineEvalInto(t *testing.T) {
+	knots, err := knot.NewArbitraryKnotBuilder(0, 0, 0.5, 1.5, 2, 3.5, 4, 6, 7.25, 9, 10).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := []float64{5, 8, 10, 8.5, 4, 0, -3.7, -5, 3.5, -2}
+	ncs, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Solve(0.1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Interpolate(y); err != nil {
+		t.Fatal(err)
+	}
+
+	sorted := make([]float64, 1000)
+	for i := range sorted {
+		sorted[i] = -2 + 14*float64(i)/float64(len(sorted)-1)
+	}
+	unsorted := []float64{7, 2, 2, -1, 11, 9.9, 0, 3.5, 3.4, 10}
+	for _, xs := range [][]float64{sorted, unsorted} {
+		dst := make([]float64, len(xs))
+		ncs.EvalInto(dst, xs)
+		for i, x := range xs {
+			if d := math.Abs(dst[i] - ncs.At(x)); d > 1e-9 {
+				t.Fatalf("[NaturalCubicSpline] EvalInto = %f, At = %f at %f", dst[i], ncs.At(x), x)
+			}
+		}
+	}
+}
+
+func BenchmarkNaturalCubicSplineAt(b *testing.B) {
+	knots, _ := knot.NewUniformKnot(0, 1, 50, 0)
+	ncs, _ := NewNaturalCubicSplines(knots, nil)
+	var sink float64
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 1000; j++ {
+			sink += ncs.At(float64(j) / 1000)
+		}
+	}
+	_ = sink
+}
+
+func BenchmarkNaturalCubicSplineEvalInto(b *testing.B) {
+	knots, _ := knot.NewUniformKnot(0, 1, 50, 0)
+	ncs, _ := NewNaturalCubicSplines(knots, nil)
+	xs := make([]float64, 1000)
+	for j := range xs {
+		xs[j] = float64(j) / 1000
+	}
+	dst := make([]float64, len(xs))
+	for i := 0; i < b.N; i++ {
+		ncs.EvalInto(dst, xs)
+	}
+}
+
+func TestNaturalCubicSplinePiecewisePolynomial(t *testing.T) {
+	knots, err := knot.NewArbitraryKnotBuilder(0, 0, 0.5, 1.5, 2, 3.5, 4, 6, 7.25, 9, 10).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ncs, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Solve(0.1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Interpolate([]float64{5, 8, 10, 8.5, 4, 0, -3.7, -5, 3.5, -2}); err != nil {
+		t.Fatal(err)
+	}
+	pp := ncs.ToPiecewisePolynomial()
+	if pp.Pieces() != knots.Count()-1 || pp.Order() != 3 {
+		t.Fatalf("[NaturalCubicSpline] %d pieces of order %d", pp.Pieces(), pp.Order())
+	}
+	for x := 0.0; x <= 10; x += 0.01 {
+		if d := math.Abs(pp.At(x) - ncs.At(x)); d > 1e-9 {
+			t.Fatalf("[NaturalCubicSpline] Piecewise polynomial = %f, At = %f at %f", pp.At(x), ncs.At(x), x)
+		}
+	}
+	// Natural boundary conditions
+	first, last := pp.Coefs[0], pp.Coefs[pp.Pieces()-1]
+	h := knots.At(knots.Count()-1) - knots.At(knots.Count()-2)
+	if math.Abs(first[2]) > 1e-12 || math.Abs(2*last[2]+6*last[3]*h) > 1e-9 {
+		t.Fatalf("[NaturalCubicSpline] Second derivatives %f, %f at the ends", 2*first[2], 2*last[2]+6*last[3]*h)
+	}
+}
+
+func TestNaturalCubicSplineRoots(t *testing.T) {
+	knots, err := knot.NewArbitraryKnotBuilder(0, 0, 0.5, 1.5, 2, 3.5, 4, 6, 7.25, 9, 10).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ncs, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Solve(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Interpolate([]float64{5, 8, 10, 8.5, 4, 0, -3.7, -5, 3.5, -2}); err != nil {
+		t.Fatal(err)
+	}
+	roots := ncs.Roots(0)
+	t.Logf("[NaturalCubicSpline] Roots %v", roots)
+	// On the line left of 0, at 4, between 7.25 and 9, and between 9 and 10
+	if len(roots) != 4 {
+		t.Fatalf("[NaturalCubicSpline] Roots %v", roots)
+	}
+	if roots[0] >= 0 || math.Abs(roots[1]-4) > 1e-9 || roots[2] < 7.25 || roots[2] > 9 || roots[3] < 9 || roots[3] > 10 {
+		t.Fatalf("[NaturalCubicSpline] Roots %v", roots)
+	}
+	for _, r := range roots {
+		if v := ncs.At(r); math.Abs(v) > 1e-9 {
+			t.Fatalf("[NaturalCubicSpline] Spline at root %f is %g", r, v)
+		}
+	}
+}
+
+func TestNaturalCubicSplineExtrema(t *testing.T) {
+	knots, err := knot.NewArbitraryKnotBuilder(0, 0, 0.5, 1.5, 2, 3.5, 4, 6, 7.25, 9, 10).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ncs, err := NewNaturalCubicSplines(knots, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Solve(0.1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ncs.Interpolate([]float64{5, 8, 10, 8.5, 4, 0, -3.7, -5, 3.5, -2}); err != nil {
+		t.Fatal(err)
+	}
+	extrema := ncs.Extrema()
+	t.Logf("[NaturalCubicSpline] Extrema %v", extrema)
+	t.Logf("[NaturalCubicSpline] Inflection points %v", ncs.InflectionPoints())
+	// Peaks near 1.5 and 9, a trough between them
+	if len(extrema) != 3 || extrema[0].Kind != ppoly.Maximum || extrema[1].Kind != ppoly.Minimum || extrema[2].Kind != ppoly.Maximum {
+		t.Fatalf("[NaturalCubicSpline] Extrema %v", extrema)
+	}
+	for _, x := range ncs.InflectionPoints() {
+		if x <= 0 || x >= 10 {
+			t.Fatalf("[NaturalCubicSpline] Inflection point %f beyond the boundary knots", x)
+		}
+	}
+
+	// The lines beyond the boundary knots
+	for _, c := range []struct{ a, b float64 }{{-5, 12}, {-5, -1}, {1, 3}, {11, 12}} {
+		x, max := ncs.Max(c.a, c.b)
+		_, min := ncs.Min(c.a, c.b)
+		gridMax, gridMin := math.Inf(-1), math.Inf(1)
+		for u := c.a; u <= c.b; u += 1e-3 {
+			gridMax = math.Max(gridMax, ncs.At(u))
+			gridMin = math.Min(gridMin, ncs.At(u))
+		}
+		gridMax = math.Max(gridMax, ncs.At(c.b))
+		gridMin = math.Min(gridMin, ncs.At(c.b))
+		if max < gridMax-1e-9 || max-gridMax > 1e-5 || min > gridMin+1e-9 || gridMin-min > 1e-5 || math.Abs(ncs.At(x)-max) > 1e-9 {
+			t.Fatalf("[NaturalCubicSpline] On [%f, %f] max %f, grid %f, min %f, grid %f", c.a, c.b, max, gridMax, min, gridMin)
+		}
+	}
+}
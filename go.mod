@@ -1,6 +1,6 @@
 module github.com/helloworldpark/gonaturalspline
 
-go 1.12
+go 1.13
 
 require (
 	golang.org/dl v0.0.0-20191205014302-95494741406c // indirect
@@ -6,43 +6,69 @@ import "strings"
 
 import "fmt"
 
+import "github.com/helloworldpark/gonaturalspline/splineerr"
+
 type arbitraryKnot struct {
 	knots   []float64
 	padding int
 }
 
 type ArbitraryKnotBuilder struct {
-	knots        map[float64]bool
+	// knots Multiplicity of each knot
+	knots        map[float64]int
 	paddingCount int
 }
 
 // NewArbitraryKnotBuilder Create an arbitrary knot with this
 func NewArbitraryKnotBuilder(paddingCount int, knots ...float64) *ArbitraryKnotBuilder {
 	builder := &ArbitraryKnotBuilder{
-		knots:        make(map[float64]bool),
+		knots:        make(map[float64]int),
 		paddingCount: paddingCount,
 	}
 	for _, f := range knots {
-		builder.knots[f] = true
+		builder.Append(f)
 	}
 	return builder
 }
 
+// Append Append a knot of multiplicity 1. Appending an existing knot again does nothing.
 func (b *ArbitraryKnotBuilder) Append(f float64) *ArbitraryKnotBuilder {
-	b.knots[f] = true
+	if b.knots[f] == 0 {
+		b.knots[f] = 1
+	}
+	return b
+}
+
+// AppendWithMultiplicity Append a knot repeated m times, replacing its previous multiplicity.
+// A B-Spline of order p is C^(p-m) continuous at a knot of multiplicity m,
+// e.g. m = p gives a kink and m = p+1 a jump.
+func (b *ArbitraryKnotBuilder) AppendWithMultiplicity(f float64, m int) *ArbitraryKnotBuilder {
+	b.knots[f] = m
 	return b
 }
 
-func (b *ArbitraryKnotBuilder) Build() Knot {
+func (b *ArbitraryKnotBuilder) Build() (Knot, error) {
+	var unique []float64
+	for k, m := range b.knots {
+		if m < 1 {
+			return nil, fmt.Errorf("[Knot] Multiplicity %d of knot %f is less than 1: %w", m, k, splineerr.ErrInvalidKnots)
+		}
+		unique = append(unique, k)
+	}
+	sort.Float64s(unique)
 	var knots []float64
-	for k := range b.knots {
-		knots = append(knots, k)
+	for _, k := range unique {
+		for i := 0; i < b.knots[k]; i++ {
+			knots = append(knots, k)
+		}
 	}
-	sort.Float64s(knots)
 
 	// Knot Check
-	if len(knots) <= 1 {
-		panic("[Knot] No knots were given")
+	if len(unique) <= 1 {
+		return nil, fmt.Errorf("[Knot] Less than 2 unique knots were given: %w", splineerr.ErrInvalidKnots)
+	}
+	if b.paddingCount < 0 {
+		return nil, fmt.Errorf("[Knot] Negative padding %d: %w", b.paddingCount, splineerr.ErrInvalidKnots)
 	}
 
 	var totalKnots []float64
@@ -56,7 +82,7 @@ func (b *ArbitraryKnotBuilder) Build() Knot {
 	return &arbitraryKnot{
 		knots:   totalKnots,
 		padding: b.paddingCount,
-	}
+	}, nil
 }
 
 func (k *arbitraryKnot) Len() int {
@@ -127,3 +153,25 @@ func (k *arbitraryKnot) String() string {
 	buf.WriteString("]")
 	return buf.String()
 }
+
+// NewSequenceKnot Creates knots from the whole sorted sequence, including the paddings
+//     k_-p, ... , k_-1, k_0, ... , k_(count-1), k_count, ... , k_(count-1+p)
+// Unlike ArbitraryKnotBuilder, the paddings are kept as given.
+func NewSequenceKnot(paddings int, knots ...float64) (Knot, error) {
+	if paddings < 0 {
+		return nil, fmt.Errorf("[Knot] Negative padding %d: %w", paddings, splineerr.ErrInvalidKnots)
+	}
+	if len(knots) < 2*paddings+2 {
+		return nil, fmt.Errorf("[Knot] %d knots with %d paddings on each end: %w", len(knots), paddings, splineerr.ErrInvalidKnots)
+	}
+	if !sort.Float64sAreSorted(knots) {
+		return nil, fmt.Errorf("[Knot] Knots are not sorted: %w", splineerr.ErrInvalidKnots)
+	}
+	if knots[paddings] >= knots[len(knots)-1-paddings] {
+		return nil, fmt.Errorf("[Knot] Less than 2 unique knots between the paddings: %w", splineerr.ErrInvalidKnots)
+	}
+	return &arbitraryKnot{
+		knots:   append([]float64{}, knots...),
+		padding: paddings,
+	}, nil
+}
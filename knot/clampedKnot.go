@@ -0,0 +1,130 @@
+package knot
+
+import "sort"
+
+import "strings"
+
+import "fmt"
+
+import "github.com/helloworldpark/gonaturalspline/splineerr"
+
+// clampedKnot Knots whose end knots are repeated order+1 times
+//     k_-p = ... = k_-1 = k_0 < k_1 < ... < k_(count-1) = k_count = ... = k_(count-1+p)
+// so that B-Splines of the order interpolate their end coefficients
+// and sum to one on the closed interval [k_0, k_(count-1)].
+type clampedKnot struct {
+	knots   []float64
+	padding int
+}
+
+// NewClampedKnot Creates a clamped knot for B-Splines of the given order
+// from strictly increasing breakpoints.
+func NewClampedKnot(order int, breaks ...float64) (Knot, error) {
+	if order < 0 {
+		return nil, fmt.Errorf("[Knot] Negative order %d: %w", order, splineerr.ErrInvalidOrder)
+	}
+	if len(breaks) <= 1 {
+		return nil, fmt.Errorf("[Knot] Less than 2 breakpoints were given: %w", splineerr.ErrInvalidKnots)
+	}
+	for i := 1; i < len(breaks); i++ {
+		if breaks[i-1] >= breaks[i] {
+			return nil, fmt.Errorf("[Knot] Breakpoints are not strictly increasing: %w", splineerr.ErrInvalidKnots)
+		}
+	}
+
+	var knots clampedKnot
+	for i := 0; i < order; i++ {
+		knots.knots = append(knots.knots, breaks[0])
+	}
+	knots.knots = append(knots.knots, breaks...)
+	for i := 0; i < order; i++ {
+		knots.knots = append(knots.knots, breaks[len(breaks)-1])
+	}
+	knots.padding = order
+	return &knots, nil
+}
+
+// NewClampedUniformKnot Creates a clamped knot with uniform intervals between start and end,
+// also known as an open uniform knot vector.
+func NewClampedUniformKnot(start, end float64, count, order int) (Knot, error) {
+	if count <= 1 {
+		return nil, fmt.Errorf("[Knot] Count %d is less than 2: %w", count, splineerr.ErrInvalidKnots)
+	}
+	if start >= end {
+		return nil, fmt.Errorf("[Knot] Start %f is not less than end %f: %w", start, end, splineerr.ErrInvalidKnots)
+	}
+	interval := (end - start) / float64(count-1)
+	breaks := make([]float64, count)
+	for i := range breaks {
+		breaks[i] = start + float64(i)*interval
+	}
+	breaks[count-1] = end
+	return NewClampedKnot(order, breaks...)
+}
+
+func (k *clampedKnot) Len() int {
+	return len(k.knots)
+}
+
+func (k *clampedKnot) Padding() int {
+	return k.padding
+}
+
+func (k *clampedKnot) Count() int {
+	return len(k.knots) - 2*k.padding
+}
+
+func (k *clampedKnot) IsSorted() bool {
+	return sort.Float64sAreSorted(k.knots)
+}
+
+// IsUnique Always false if padded, since the end knots are repeated
+func (k *clampedKnot) IsUnique() bool {
+	if k.Len() == 0 {
+		return false
+	}
+	last := k.knots[0]
+	for _, f := range k.knots[1:] {
+		if last == f {
+			return false
+		}
+		last = f
+	}
+	return true
+}
+
+func (k *clampedKnot) At(idx int) float64 {
+	idx += k.Padding()
+	if idx < 0 {
+		return k.knots[0]
+	}
+	if idx >= k.Len() {
+		return k.knots[k.Len()-1]
+	}
+	return k.knots[idx]
+}
+
+func (k *clampedKnot) Index(x float64) int {
+	idx := sort.Search(len(k.knots), func(i int) bool {
+		return k.knots[i] >= x
+	})
+	if idx == 0 {
+		return -k.Padding()
+	}
+	// -1: since sort.Search returns smallest idx s.t. k.knots[idx] >= x,
+	//     the knot should be knot_(idx-1) < x <= knot_idx
+	return idx - 1 - k.Padding()
+}
+
+func (k *clampedKnot) String() string {
+	buf := strings.Builder{}
+	buf.WriteString(fmt.Sprintf("ClampedKnot(Count: %d, Padding: %d)[", k.Count(), k.Padding()))
+	for i, f := range k.knots {
+		buf.WriteString(fmt.Sprintf("%f", f))
+		if i < k.Len()-1 {
+			buf.WriteString(", ")
+		}
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
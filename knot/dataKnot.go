@@ -0,0 +1,151 @@
+package knot
+
+import "math"
+
+import "sort"
+
+import "fmt"
+
+import "github.com/helloworldpark/gonaturalspline/splineerr"
+
+// NewQuantileKnot Creates knots at count empirical quantiles of x, from min(x) to max(x).
+// Coinciding quantiles of tied x are merged, so there may be fewer knots than count.
+// Paddings repeat the end knots, as in ArbitraryKnotBuilder.
+func NewQuantileKnot(x []float64, count, paddings int) (Knot, error) {
+	if count <= 1 {
+		return nil, fmt.Errorf("[Knot] Count %d is less than 2: %w", count, splineerr.ErrInvalidKnots)
+	}
+	q, err := Quantiles(x, count)
+	if err != nil {
+		return nil, err
+	}
+	return NewArbitraryKnotBuilder(paddings, q...).Build()
+}
+
+// NewUniqueKnot Creates knots at the unique values of x.
+// If there are more than maxCount of them, maxCount knots are taken
+// at evenly spaced ranks, always including min(x) and max(x), as R's smooth.spline does.
+// See SmoothSplineKnotCount for a maxCount growing with the data.
+// Paddings repeat the end knots, as in ArbitraryKnotBuilder.
+func NewUniqueKnot(x []float64, maxCount, paddings int) (Knot, error) {
+	if maxCount <= 1 {
+		return nil, fmt.Errorf("[Knot] Maximum count %d is less than 2: %w", maxCount, splineerr.ErrInvalidKnots)
+	}
+	unique, err := sortedUnique(x)
+	if err != nil {
+		return nil, err
+	}
+	knots := unique
+	if len(unique) > maxCount {
+		knots = make([]float64, maxCount)
+		for i := range knots {
+			knots[i] = unique[i*(len(unique)-1)/(maxCount-1)]
+		}
+	}
+	return NewArbitraryKnotBuilder(paddings, knots...).Build()
+}
+
+// NewMinSpacingKnot Creates knots at the candidates, such as x or Quantiles(x, count),
+// dropping those closer than minSpacing to the previous knot.
+// The smallest and the largest candidates are always kept.
+// Paddings repeat the end knots, as in ArbitraryKnotBuilder.
+func NewMinSpacingKnot(candidates []float64, minSpacing float64, paddings int) (Knot, error) {
+	if minSpacing < 0 || math.IsNaN(minSpacing) {
+		return nil, fmt.Errorf("[Knot] Minimum spacing %f: %w", minSpacing, splineerr.ErrInvalidKnots)
+	}
+	unique, err := sortedUnique(candidates)
+	if err != nil {
+		return nil, err
+	}
+	knots := []float64{unique[0]}
+	for _, c := range unique[1 : len(unique)-1] {
+		if c-knots[len(knots)-1] >= minSpacing {
+			knots = append(knots, c)
+		}
+	}
+	last := unique[len(unique)-1]
+	if len(knots) > 1 && last-knots[len(knots)-1] < minSpacing {
+		knots[len(knots)-1] = last
+	} else {
+		knots = append(knots, last)
+	}
+	return NewArbitraryKnotBuilder(paddings, knots...).Build()
+}
+
+// Quantiles count empirical quantiles of x at probabilities 0, 1/(count-1), ... , 1,
+// interpolated linearly between the order statistics.
+func Quantiles(x []float64, count int) ([]float64, error) {
+	if len(x) == 0 {
+		return nil, fmt.Errorf("[Knot] No data were given: %w", splineerr.ErrInvalidKnots)
+	}
+	if count <= 0 {
+		return nil, fmt.Errorf("[Knot] Count %d is not positive: %w", count, splineerr.ErrInvalidKnots)
+	}
+	sorted := make([]float64, len(x))
+	copy(sorted, x)
+	for _, v := range sorted {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("[Knot] Data %f: %w", v, splineerr.ErrInvalidKnots)
+		}
+	}
+	sort.Float64s(sorted)
+
+	q := make([]float64, count)
+	if count == 1 {
+		q[0] = sorted[0]
+		return q, nil
+	}
+	for i := range q {
+		h := float64(len(sorted)-1) * float64(i) / float64(count-1)
+		lo := int(math.Floor(h))
+		if lo >= len(sorted)-1 {
+			q[i] = sorted[len(sorted)-1]
+			continue
+		}
+		q[i] = sorted[lo] + (h-float64(lo))*(sorted[lo+1]-sorted[lo])
+	}
+	return q, nil
+}
+
+// SmoothSplineKnotCount Number of knots for n unique abscissae used by R's smooth.spline,
+// all of them below 50 and growing slowly beyond.
+func SmoothSplineKnotCount(n int) int {
+	if n < 50 {
+		return n
+	}
+	a1, a2, a3, a4 := math.Log2(50), math.Log2(100), math.Log2(140), math.Log2(200)
+	m := float64(n)
+	var count float64
+	switch {
+	case n < 200:
+		count = math.Exp2(a1 + (a2-a1)*(m-50)/150)
+	case n < 800:
+		count = math.Exp2(a2 + (a3-a2)*(m-200)/600)
+	case n < 3200:
+		count = math.Exp2(a3 + (a4-a3)*(m-800)/2400)
+	default:
+		count = 200 + math.Pow(m-3200, 0.2)
+	}
+	// Truncated, without losing 2^log2(50) = 49.999...
+	return int(count + 1e-9)
+}
+
+// sortedUnique Unique values of x in increasing order, at least 2 of them
+func sortedUnique(x []float64) ([]float64, error) {
+	sorted := make([]float64, len(x))
+	copy(sorted, x)
+	sort.Float64s(sorted)
+	var unique []float64
+	for i, v := range sorted {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("[Knot] Data %f: %w", v, splineerr.ErrInvalidKnots)
+		}
+		if i == 0 || v != sorted[i-1] {
+			unique = append(unique, v)
+		}
+	}
+	if len(unique) <= 1 {
+		return nil, fmt.Errorf("[Knot] Less than 2 unique values were given: %w", splineerr.ErrInvalidKnots)
+	}
+	return unique, nil
+}
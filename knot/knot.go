@@ -12,6 +12,8 @@ package knot
 //     k_-p, k_(-p+1), ... , k_-1, k_0, k_1, ... , k_count, k_(count+1), ... , k_(count+p)
 //     --------------------------  ^^^^^^^^^^^^^^^^^^^^^^^  ------------------------------
 //             PADDINGS                    KNOTS                       PADDINGS
+// A clamped knot repeats k_0 and k_(count-1) as its paddings instead,
+// so that no padding beyond k_(count-1) is needed for y = spline(k_(count-1)).
 type Knot interface {
 	// Len
 	// Total length of the knots, including paddings
@@ -32,3 +34,14 @@ type Knot interface {
 	IsSorted() bool
 	IsUnique() bool
 }
+
+// Multiplicity How many times x appears in the knots, including paddings
+func Multiplicity(knots Knot, x float64) int {
+	var m int
+	for i := -knots.Padding(); i < knots.Count()+knots.Padding(); i++ {
+		if knots.At(i) == x {
+			m++
+		}
+	}
+	return m
+}
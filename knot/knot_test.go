@@ -1,12 +1,21 @@
 package knot
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
+	"sort"
 	"testing"
+
+	"github.com/helloworldpark/gonaturalspline/splineerr"
 )
 
 func TestUniformKnot(t *testing.T) {
-	knot := NewUniformKnot(0, 10, 100, 4)
+	knot, err := NewUniformKnot(0, 10, 100, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
 	if !knot.IsSorted() {
 		t.Fatal("[Knot] Knot is not sorted!")
 	}
@@ -21,7 +30,10 @@ func TestUniformKnot(t *testing.T) {
 }
 
 func TestKnotIndex(t *testing.T) {
-	knots := NewUniformKnot(2, 3, 10, 4)
+	knots, err := NewUniformKnot(2, 3, 10, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
 	for x := 0.0; x < 2.0; x += 0.00001 {
 		fmt.Printf("x[%d]=%f\n", knots.Index(x), x)
 	}
@@ -30,7 +42,217 @@ func TestKnotIndex(t *testing.T) {
 func TestArbitraryKnot(t *testing.T) {
 	builder := NewArbitraryKnotBuilder(3)
 	builder = builder.Append(0.0).Append(1.0).Append(1.5).Append(0.1).Append(3.1415)
-	knot := builder.Build()
+	knot, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
 
 	fmt.Println(knot, knot.Padding())
 }
+
+func TestKnotErrors(t *testing.T) {
+	if _, err := NewUniformKnot(0, 1, 1, 3); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[Knot] Expected ErrInvalidKnots for count 1, got %v", err)
+	}
+	if _, err := NewUniformKnot(1, 0, 10, 3); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[Knot] Expected ErrInvalidKnots for start > end, got %v", err)
+	}
+	if _, err := NewArbitraryKnotBuilder(3, 1.0, 1.0).Build(); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[Knot] Expected ErrInvalidKnots for a single unique knot, got %v", err)
+	}
+}
+
+func TestClampedKnot(t *testing.T) {
+	const order = 3
+	knots, err := NewClampedUniformKnot(0, 1, 5, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(knots, knots.Padding())
+	if knots.Count() != 5 || knots.Padding() != order {
+		t.Fatalf("[Knot] Count %d, padding %d", knots.Count(), knots.Padding())
+	}
+	for i := -order; i <= 0; i++ {
+		if knots.At(i) != 0 || knots.At(knots.Count()-1-i) != 1 {
+			t.Fatalf("[Knot] End knots are not repeated: %v", knots)
+		}
+	}
+	if knots.At(2) != 0.5 {
+		t.Fatalf("[Knot] Interior knot %f, expected 0.5", knots.At(2))
+	}
+	if _, err := NewClampedKnot(order, 0, 0.5, 0.5, 1); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[Knot] Expected ErrInvalidKnots for repeated breakpoints, got %v", err)
+	}
+	if _, err := NewClampedKnot(-1, 0, 1); !errors.Is(err, splineerr.ErrInvalidOrder) {
+		t.Fatalf("[Knot] Expected ErrInvalidOrder, got %v", err)
+	}
+}
+
+func TestKnotMultiplicity(t *testing.T) {
+	knots, err := NewArbitraryKnotBuilder(2, 0, 1).AppendWithMultiplicity(0.5, 3).Append(0.5).Append(0.25).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(knots, knots.Padding())
+	if knots.Count() != 6 {
+		t.Fatalf("[Knot] Count %d, expected 6", knots.Count())
+	}
+	if m := Multiplicity(knots, 0.5); m != 3 {
+		t.Fatalf("[Knot] Multiplicity of 0.5 is %d, expected 3", m)
+	}
+	if m := Multiplicity(knots, 1); m != 3 {
+		t.Fatalf("[Knot] Multiplicity of 1 is %d with paddings, expected 3", m)
+	}
+	if knots.IsUnique() {
+		t.Fatalf("[Knot] Repeated knots are unique")
+	}
+	if idx := knots.Index(0.6); knots.At(idx) != 0.5 || knots.At(idx+1) != 1 {
+		t.Fatalf("[Knot] Index(0.6) = %d", idx)
+	}
+	if _, err := NewArbitraryKnotBuilder(0, 0, 1).AppendWithMultiplicity(0.5, 0).Build(); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[Knot] Expected ErrInvalidKnots for multiplicity 0, got %v", err)
+	}
+}
+
+func TestPeriodicKnot(t *testing.T) {
+	knots, err := NewPeriodicKnot(3, 0, 1, 3, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(knots, knots.Padding())
+	if knots.Period() != 4 || knots.Count() != 4 {
+		t.Fatalf("[Knot] Period %f, count %d", knots.Period(), knots.Count())
+	}
+	expected := map[int]float64{-4: -5, -3: -4, -2: -3, -1: -1, 0: 0, 3: 4, 4: 5, 5: 7, 6: 8, 10: 13}
+	for idx, v := range expected {
+		if knots.At(idx) != v {
+			t.Fatalf("[Knot] At(%d) = %f, expected %f", idx, knots.At(idx), v)
+		}
+	}
+	if idx := knots.Index(3.5); idx != 2 {
+		t.Fatalf("[Knot] Index(3.5) = %d, expected 2", idx)
+	}
+	if idx := knots.Index(-0.5); idx != -1 {
+		t.Fatalf("[Knot] Index(-0.5) = %d, expected -1", idx)
+	}
+	if _, err := NewPeriodicKnot(3, 0, 1, 1); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[Knot] Expected ErrInvalidKnots for repeated breakpoints, got %v", err)
+	}
+}
+
+func TestDataKnot(t *testing.T) {
+	// Bursty sampling: most of the data in [0, 1], a few up to 100
+	x := []float64{0, 0.1, 0.1, 0.2, 0.3, 0.5, 0.5, 0.7, 0.9, 1, 50, 100}
+	quantile, err := NewQuantileKnot(x, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(quantile, quantile.Padding())
+	for i, v := range []float64{0, 0.175, 0.5, 0.925, 100} {
+		if math.Abs(quantile.At(i)-v) > 1e-12 {
+			t.Fatalf("[Knot] Quantile knot %d = %f, expected %f", i, quantile.At(i), v)
+		}
+	}
+
+	unique, err := NewUniqueKnot(x, 4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(unique, unique.Padding())
+	for i, v := range []float64{0, 0.3, 0.9, 100} {
+		if unique.At(i) != v {
+			t.Fatalf("[Knot] Unique knot %d = %f, expected %f", i, unique.At(i), v)
+		}
+	}
+	if all, _ := NewUniqueKnot(x, 100, 0); all.Count() != 10 {
+		t.Fatalf("[Knot] %d unique knots, expected 10", all.Count())
+	}
+
+	spaced, err := NewMinSpacingKnot(x, 0.25, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(spaced)
+	for i, v := range []float64{0, 0.3, 0.7, 1, 50, 100} {
+		if spaced.At(i) != v {
+			t.Fatalf("[Knot] Spaced knot %d = %f, expected %f", i, spaced.At(i), v)
+		}
+	}
+
+	for n, count := range map[int]int{10: 10, 50: 50, 200: 100, 800: 140, 3200: 200} {
+		if c := SmoothSplineKnotCount(n); c != count {
+			t.Fatalf("[Knot] SmoothSplineKnotCount(%d) = %d, expected %d", n, c, count)
+		}
+	}
+
+	if _, err := NewQuantileKnot([]float64{1, 1, 1}, 5, 3); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[Knot] Expected ErrInvalidKnots for constant data, got %v", err)
+	}
+	if _, err := NewUniqueKnot([]float64{0, math.NaN(), 1}, 5, 3); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[Knot] Expected ErrInvalidKnots for NaN, got %v", err)
+	}
+}
+
+func TestUniformKnotIndex(t *testing.T) {
+	knots, err := NewUniformKnot(0.1, 0.7, 7, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uniform := knots.(UniformKnot)
+	if uniform.Start() != 0.1 || math.Abs(uniform.Spacing()-0.1) > 1e-15 {
+		t.Fatalf("[Knot] Start %f, spacing %f", uniform.Start(), uniform.Spacing())
+	}
+
+	padded := make([]float64, knots.Len())
+	for i := range padded {
+		padded[i] = knots.At(i - knots.Padding())
+	}
+	search := func(x float64) int {
+		idx := sort.Search(len(padded), func(i int) bool {
+			return padded[i] >= x
+		})
+		if idx == 0 {
+			return -knots.Padding()
+		}
+		return idx - 1 - knots.Padding()
+	}
+
+	xs := []float64{math.Inf(-1), math.Inf(1), math.NaN(), -1, 2}
+	for _, k := range padded {
+		// Knots and their floating point neighbours are the boundary cases
+		xs = append(xs, k, math.Nextafter(k, math.Inf(-1)), math.Nextafter(k, math.Inf(1)))
+	}
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		xs = append(xs, -0.3+1.4*rnd.Float64())
+	}
+	for _, x := range xs {
+		if idx, expected := knots.Index(x), search(x); idx != expected {
+			t.Fatalf("[Knot] Index(%v) = %d, binary search %d", x, idx, expected)
+		}
+	}
+}
+
+func BenchmarkUniformKnotIndex(b *testing.B) {
+	knots, _ := NewUniformKnot(0, 1, 1000, 3)
+	for i := 0; i < b.N; i++ {
+		knots.Index(float64(i%997) / 997)
+	}
+}
+
+func TestSequenceKnot(t *testing.T) {
+	knots, err := NewSequenceKnot(2, -0.2, -0.1, 0, 0.5, 0.5, 1, 1.3, 1.7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Println(knots, knots.Padding())
+	if knots.Count() != 4 || knots.At(-2) != -0.2 || knots.At(5) != 1.7 || knots.At(3) != 1 {
+		t.Fatalf("[Knot] Unexpected sequence knots %v", knots)
+	}
+	if _, err := NewSequenceKnot(1, 0, 1, 0.5, 2); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[Knot] Expected ErrInvalidKnots for unsorted knots, got %v", err)
+	}
+	if _, err := NewSequenceKnot(2, 0, 0, 1, 1, 2); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[Knot] Expected ErrInvalidKnots for too many paddings, got %v", err)
+	}
+}
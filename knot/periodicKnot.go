@@ -0,0 +1,130 @@
+package knot
+
+import "math"
+
+import "sort"
+
+import "strings"
+
+import "fmt"
+
+import "github.com/helloworldpark/gonaturalspline/splineerr"
+
+// PeriodicKnot Knot repeating itself with the period k_(count-1) - k_0, i.e.
+//     k_(i+count-1) = k_i + period
+// for every i, so that At wraps around the period instead of clamping at the paddings.
+type PeriodicKnot interface {
+	Knot
+	Period() float64
+}
+
+type periodicKnot struct {
+	// breaks k_0 < k_1 < ... < k_(count-1)
+	breaks  []float64
+	knots   []float64
+	padding int
+}
+
+// NewPeriodicKnot Creates a periodic knot from strictly increasing breakpoints,
+// with the period from the first to the last breakpoint.
+func NewPeriodicKnot(paddings int, breaks ...float64) (PeriodicKnot, error) {
+	if len(breaks) <= 1 {
+		return nil, fmt.Errorf("[Knot] Less than 2 breakpoints were given: %w", splineerr.ErrInvalidKnots)
+	}
+	for i := 1; i < len(breaks); i++ {
+		if breaks[i-1] >= breaks[i] {
+			return nil, fmt.Errorf("[Knot] Breakpoints are not strictly increasing: %w", splineerr.ErrInvalidKnots)
+		}
+	}
+	if paddings < 0 {
+		return nil, fmt.Errorf("[Knot] Negative padding %d: %w", paddings, splineerr.ErrInvalidKnots)
+	}
+
+	knots := &periodicKnot{
+		breaks:  append([]float64{}, breaks...),
+		padding: paddings,
+	}
+	for i := -paddings; i < len(breaks)+paddings; i++ {
+		knots.knots = append(knots.knots, knots.At(i))
+	}
+	return knots, nil
+}
+
+// NewPeriodicUniformKnot Creates a periodic knot with uniform intervals,
+// whose period is end - start.
+func NewPeriodicUniformKnot(start, end float64, count, paddings int) (PeriodicKnot, error) {
+	if count <= 1 {
+		return nil, fmt.Errorf("[Knot] Count %d is less than 2: %w", count, splineerr.ErrInvalidKnots)
+	}
+	if start >= end {
+		return nil, fmt.Errorf("[Knot] Start %f is not less than end %f: %w", start, end, splineerr.ErrInvalidKnots)
+	}
+	interval := (end - start) / float64(count-1)
+	breaks := make([]float64, count)
+	for i := range breaks {
+		breaks[i] = start + float64(i)*interval
+	}
+	breaks[count-1] = end
+	return NewPeriodicKnot(paddings, breaks...)
+}
+
+func (k *periodicKnot) Period() float64 {
+	return k.breaks[len(k.breaks)-1] - k.breaks[0]
+}
+
+func (k *periodicKnot) Len() int {
+	return len(k.knots)
+}
+
+func (k *periodicKnot) Padding() int {
+	return k.padding
+}
+
+func (k *periodicKnot) Count() int {
+	return len(k.breaks)
+}
+
+func (k *periodicKnot) IsSorted() bool {
+	return sort.Float64sAreSorted(k.knots)
+}
+
+func (k *periodicKnot) IsUnique() bool {
+	return true
+}
+
+// At Wraps around the period for any idx, even beyond the paddings
+func (k *periodicKnot) At(idx int) float64 {
+	n := len(k.breaks) - 1
+	q := int(math.Floor(float64(idx) / float64(n)))
+	r := idx - q*n
+	if r == 0 && q > 0 {
+		// k_(count-1) exactly as given, not k_0 + period
+		r, q = n, q-1
+	}
+	return k.breaks[r] + float64(q)*k.Period()
+}
+
+func (k *periodicKnot) Index(x float64) int {
+	idx := sort.Search(len(k.knots), func(i int) bool {
+		return k.knots[i] >= x
+	})
+	if idx == 0 {
+		return -k.Padding()
+	}
+	// -1: since sort.Search returns smallest idx s.t. k.knots[idx] >= x,
+	//     the knot should be knot_(idx-1) < x <= knot_idx
+	return idx - 1 - k.Padding()
+}
+
+func (k *periodicKnot) String() string {
+	buf := strings.Builder{}
+	buf.WriteString(fmt.Sprintf("PeriodicKnot(Count: %d, Padding: %d, Period: %f)[", k.Count(), k.Padding(), k.Period()))
+	for i, f := range k.knots {
+		buf.WriteString(fmt.Sprintf("%f", f))
+		if i < k.Len()-1 {
+			buf.WriteString(", ")
+		}
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
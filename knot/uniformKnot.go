@@ -1,23 +1,40 @@
 package knot
 
+import "math"
+
 import "sort"
 
 import "strings"
 
 import "fmt"
 
+import "github.com/helloworldpark/gonaturalspline/splineerr"
+
+// UniformKnot Knot with a constant spacing, k_i = Start() + i * Spacing()
+type UniformKnot interface {
+	Knot
+	// Start k_0
+	Start() float64
+	// Spacing k_(i+1) - k_i
+	Spacing() float64
+}
+
 type uniformKnot struct {
-	knots   []float64
-	padding int
+	knots    []float64
+	padding  int
+	interval float64
 }
 
-// NewUniformKnot Creates a new Knot with uniform intervals
-func NewUniformKnot(start, end float64, count, paddings int) Knot {
-	if count <= 0 {
-		return nil
+// NewUniformKnot Creates a new Knot with uniform intervals, which implements UniformKnot
+func NewUniformKnot(start, end float64, count, paddings int) (Knot, error) {
+	if count <= 1 {
+		return nil, fmt.Errorf("[Knot] Count %d is less than 2: %w", count, splineerr.ErrInvalidKnots)
 	}
 	if start >= end {
-		return nil
+		return nil, fmt.Errorf("[Knot] Start %f is not less than end %f: %w", start, end, splineerr.ErrInvalidKnots)
+	}
+	if paddings < 0 {
+		return nil, fmt.Errorf("[Knot] Negative padding %d: %w", paddings, splineerr.ErrInvalidKnots)
 	}
 	interval := (end - start) / float64(count-1)
 	var knots uniformKnot
@@ -31,7 +48,8 @@ func NewUniformKnot(start, end float64, count, paddings int) Knot {
 		knots.knots = append(knots.knots, end+float64(i)*interval)
 	}
 	knots.padding = paddings
-	return &knots
+	knots.interval = interval
+	return &knots, nil
 }
 
 func (k *uniformKnot) Len() int {
@@ -78,18 +96,48 @@ func (k *uniformKnot) At(idx int) float64 {
 	return k.knots[idx]
 }
 
+// Index Same as a binary search over the knots, but computed directly from the spacing.
+// The guess is corrected against the stored knots, so rounding never changes the result.
 func (k *uniformKnot) Index(x float64) int {
-	idx := sort.Search(len(k.knots), func(i int) bool {
-		return k.knots[i] >= x
-	})
-	if idx == 0 {
+	last := len(k.knots) - 1
+	if !(x > k.knots[0]) {
+		if math.IsNaN(x) {
+			// Like sort.Search, for which no knot is >= NaN
+			return last - k.Padding()
+		}
 		return -k.Padding()
 	}
-	// -1: since sort.Search returns smallest idx s.t. k.knots[idx] >= x,
-	//     the knot should be knot_(idx-1) <= x < knot_idx
+	if x > k.knots[last] {
+		return last - k.Padding()
+	}
+	// idx: smallest index s.t. k.knots[idx] >= x
+	idx := int(math.Ceil((x - k.knots[0]) / k.interval))
+	if idx < 1 {
+		idx = 1
+	}
+	if idx > last {
+		idx = last
+	}
+	for idx > 1 && k.knots[idx-1] >= x {
+		idx--
+	}
+	for idx < last && k.knots[idx] < x {
+		idx++
+	}
+	// -1: the knot should be knot_(idx-1) < x <= knot_idx
 	return idx - 1 - k.Padding()
 }
 
+// Start k_0
+func (k *uniformKnot) Start() float64 {
+	return k.knots[k.Padding()]
+}
+
+// Spacing k_(i+1) - k_i
+func (k *uniformKnot) Spacing() float64 {
+	return k.interval
+}
+
 func (k *uniformKnot) String() string {
 	buf := strings.Builder{}
 	buf.WriteString(fmt.Sprintf("UniformKnot(Count: %d, Padding: %d)[", k.Count(), k.Padding()))
@@ -0,0 +1,135 @@
+package ppoly
+
+import (
+	"math"
+	"sort"
+)
+
+// ExtremumKind Whether an extremum is a minimum or a maximum
+type ExtremumKind int
+
+const (
+	// Minimum Local minimum
+	Minimum ExtremumKind = iota
+	// Maximum Local maximum
+	Maximum
+)
+
+func (k ExtremumKind) String() string {
+	if k == Maximum {
+		return "maximum"
+	}
+	return "minimum"
+}
+
+// Extremum Local extremum at X
+type Extremum struct {
+	X, Value float64
+	Kind     ExtremumKind
+}
+
+// Extrema Strict local minima and maxima in the interior of the breaks, in increasing order.
+// The breaks and the roots of the derivative split the breaks into intervals where p is monotone,
+// and an extremum is where the direction of p turns, at a root of the derivative or at a kink.
+// p is assumed to be continuous; a piece constant throughout is no extremum.
+func (p *PiecewisePolynomial) Extrema() []Extremum {
+	xs, falling := p.Derivative().signChanges()
+	extrema := make([]Extremum, len(xs))
+	for i, x := range xs {
+		extrema[i] = Extremum{X: x, Value: p.At(x), Kind: Maximum}
+		if !falling[i] {
+			extrema[i].Kind = Minimum
+		}
+	}
+	return extrema
+}
+
+// InflectionPoints Abscissae in the interior of the breaks where the second derivative changes sign,
+// in increasing order, found as in Extrema
+func (p *PiecewisePolynomial) InflectionPoints() []float64 {
+	xs, _ := p.Derivative().Derivative().signChanges()
+	return xs
+}
+
+// Min Smallest value of p on [a, b] and where it is attained, among a, b,
+// the breaks and the roots of the derivative in between.
+// Beyond the breaks the end pieces are extrapolated, as in At.
+func (p *PiecewisePolynomial) Min(a, b float64) (x, value float64) {
+	return p.extreme(a, b, func(v, best float64) bool { return v < best })
+}
+
+// Max Largest value of p on [a, b] and where it is attained, see Min
+func (p *PiecewisePolynomial) Max(a, b float64) (x, value float64) {
+	return p.extreme(a, b, func(v, best float64) bool { return v > best })
+}
+
+// extreme Best value of p on [a, b] by better, and where it is attained
+func (p *PiecewisePolynomial) extreme(a, b float64, better func(v, best float64) bool) (float64, float64) {
+	if a > b {
+		a, b = b, a
+	}
+	x, value := a, p.At(a)
+	for i, c := range p.Coefs {
+		// Part of [a, b] on the piece, extended beyond the breaks on the end pieces
+		lo, hi := math.Max(a, p.Breaks[i]), math.Min(b, p.Breaks[i+1])
+		if i == 0 {
+			lo = a
+		}
+		if i == len(p.Coefs)-1 {
+			hi = b
+		}
+		if lo > hi {
+			continue
+		}
+		candidates := []float64{lo - p.Breaks[i], hi - p.Breaks[i]}
+		if d := derivative(c); degree(d) >= 0 {
+			candidates = append(candidates, polynomialRoots(d, lo-p.Breaks[i], hi-p.Breaks[i])...)
+		}
+		for _, t := range candidates {
+			if v := horner(c, t); better(v, value) {
+				x, value = p.Breaks[i]+t, v
+			}
+		}
+	}
+	return x, value
+}
+
+// signChanges Abscissae in the interior of the breaks where p changes sign between the
+// intervals split at its roots and the breaks, and whether it turns from positive to negative there.
+// The sign on each interval is taken at its midpoint, where p has no root.
+func (p *PiecewisePolynomial) signChanges() ([]float64, []bool) {
+	first, last := p.Breaks[0], p.Breaks[len(p.Breaks)-1]
+	tol := RootTolerance * (last - first + math.Abs(first) + math.Abs(last))
+
+	points := append(append([]float64{}, p.Breaks...), p.Roots(0)...)
+	sort.Float64s(points)
+	merged := points[:1]
+	for _, x := range points[1:] {
+		if x-merged[len(merged)-1] > tol {
+			merged = append(merged, x)
+		}
+	}
+
+	var xs []float64
+	var falling []bool
+	var previous float64
+	for i := 0; i+1 < len(merged); i++ {
+		sign := sign(p.At((merged[i] + merged[i+1]) / 2))
+		if i > 0 && sign != 0 && previous == -sign {
+			xs = append(xs, merged[i])
+			falling = append(falling, sign < 0)
+		}
+		previous = sign
+	}
+	return xs, falling
+}
+
+func sign(v float64) float64 {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
@@ -0,0 +1,154 @@
+// Package ppoly Piecewise polynomials in the local power form, as returned by
+// the ToPiecewisePolynomial of the splines of gonaturalspline.
+package ppoly
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+)
+
+// PiecewisePolynomial On the piece [Breaks[i], Breaks[i+1]) the function is
+//     sum_k Coefs[i][k] * (x - Breaks[i])^k
+// so Coefs[i][k] is the k-th derivative at Breaks[i] divided by k!.
+// The last piece is closed, and beyond the breaks the end pieces are extrapolated,
+// as MATLAB ppval and SciPy PPoly do.
+type PiecewisePolynomial struct {
+	Breaks []float64
+	Coefs  [][]float64
+}
+
+// NewPiecewisePolynomial Piecewise polynomial on the breaks with the local coefficients.
+// Requires len(breaks) >= 2 strictly increasing breaks, and len(breaks) - 1 pieces
+// of the same positive number of coefficients.
+func NewPiecewisePolynomial(breaks []float64, coefs [][]float64) (*PiecewisePolynomial, error) {
+	if len(breaks) < 2 {
+		return nil, fmt.Errorf("[PPoly] Less than 2 breaks: %w", splineerr.ErrInvalidKnots)
+	}
+	for i := 1; i < len(breaks); i++ {
+		if !(breaks[i-1] < breaks[i]) {
+			return nil, fmt.Errorf("[PPoly] Breaks are not strictly increasing: %w", splineerr.ErrInvalidKnots)
+		}
+	}
+	if len(coefs) != len(breaks)-1 {
+		return nil, fmt.Errorf("[PPoly] %d pieces for %d breaks: %w", len(coefs), len(breaks), splineerr.ErrCoefLength)
+	}
+	for i, c := range coefs {
+		if len(c) == 0 || len(c) != len(coefs[0]) {
+			return nil, fmt.Errorf("[PPoly] %d coefficients on the piece %d, %d on the first: %w", len(c), i, len(coefs[0]), splineerr.ErrCoefLength)
+		}
+	}
+	return &PiecewisePolynomial{Breaks: breaks, Coefs: coefs}, nil
+}
+
+// Order Degree of the pieces, one less than the number of coefficients of each
+func (p *PiecewisePolynomial) Order() int {
+	return len(p.Coefs[0]) - 1
+}
+
+// Pieces Number of pieces, len(Breaks) - 1
+func (p *PiecewisePolynomial) Pieces() int {
+	return len(p.Coefs)
+}
+
+// Piece Index of the piece x belongs to, with the end pieces extended beyond the breaks
+func (p *PiecewisePolynomial) Piece(x float64) int {
+	i := sort.Search(len(p.Breaks), func(i int) bool { return p.Breaks[i] > x }) - 1
+	if i < 0 {
+		return 0
+	}
+	if i >= len(p.Coefs) {
+		return len(p.Coefs) - 1
+	}
+	return i
+}
+
+func (p *PiecewisePolynomial) At(x float64) float64 {
+	i := p.Piece(x)
+	return horner(p.Coefs[i], x-p.Breaks[i])
+}
+
+// EvalInto dst[i] = At(xs[i]). Panics if len(dst) < len(xs).
+// The piece of each abscissa is searched forward from that of the previous one,
+// so sorted xs need no binary search.
+func (p *PiecewisePolynomial) EvalInto(dst, xs []float64) {
+	if len(dst) < len(xs) {
+		panic(fmt.Sprintf("[PPoly] EvalInto: %d destinations for %d abscissae", len(dst), len(xs)))
+	}
+	i := 0
+	for j, x := range xs {
+		if x < p.Breaks[i] {
+			i = p.Piece(x)
+		}
+		for i < len(p.Coefs)-1 && p.Breaks[i+1] <= x {
+			i++
+		}
+		dst[j] = horner(p.Coefs[i], x-p.Breaks[i])
+	}
+}
+
+// DescendingCoefs Coefficients of each piece from the highest power down,
+// the layout of the coefficient matrix of MATLAB mkpp, and of the transposed c of SciPy PPoly.
+func (p *PiecewisePolynomial) DescendingCoefs() [][]float64 {
+	coefs := make([][]float64, len(p.Coefs))
+	for i, c := range p.Coefs {
+		coefs[i] = make([]float64, len(c))
+		for k, v := range c {
+			coefs[i][len(c)-1-k] = v
+		}
+	}
+	return coefs
+}
+
+// Sub p - q on the union of their breaks, each extrapolated beyond its own breaks as in At.
+// The pieces have as many coefficients as the longer of p and q.
+func (p *PiecewisePolynomial) Sub(q *PiecewisePolynomial) *PiecewisePolynomial {
+	breaks := append(append([]float64{}, p.Breaks...), q.Breaks...)
+	sort.Float64s(breaks)
+	unique := breaks[:1]
+	for _, x := range breaks[1:] {
+		if x != unique[len(unique)-1] {
+			unique = append(unique, x)
+		}
+	}
+
+	n := len(p.Coefs[0])
+	if len(q.Coefs[0]) > n {
+		n = len(q.Coefs[0])
+	}
+	coefs := make([][]float64, len(unique)-1)
+	for i := range coefs {
+		u := unique[i]
+		coefs[i] = make([]float64, n)
+		j := p.Piece(u)
+		for k, v := range shift(p.Coefs[j], u-p.Breaks[j]) {
+			coefs[i][k] += v
+		}
+		j = q.Piece(u)
+		for k, v := range shift(q.Coefs[j], u-q.Breaks[j]) {
+			coefs[i][k] -= v
+		}
+	}
+	return &PiecewisePolynomial{Breaks: unique, Coefs: coefs}
+}
+
+// shift Coefficients of sum_k c[k] * (t + h)^k in powers of t, by repeated synthetic division
+func shift(c []float64, h float64) []float64 {
+	s := append([]float64{}, c...)
+	for i := 0; i < len(s); i++ {
+		for k := len(s) - 2; k >= i; k-- {
+			s[k] += h * s[k+1]
+		}
+	}
+	return s
+}
+
+// horner sum_k c[k] * t^k
+func horner(c []float64, t float64) float64 {
+	var y float64
+	for k := len(c) - 1; k >= 0; k-- {
+		y = y*t + c[k]
+	}
+	return y
+}
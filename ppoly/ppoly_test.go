@@ -0,0 +1,239 @@
+package ppoly
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+)
+
+func TestPiecewisePolynomial(t *testing.T) {
+	// |x| on [-1, 1], and x^2 - 1 on [1, 3]
+	pp, err := NewPiecewisePolynomial([]float64{-1, 0, 1, 3}, [][]float64{
+		{1, -1, 0},
+		{0, 1, 0},
+		{0, 2, 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	truth := func(x float64) float64 {
+		switch {
+		case x < 0:
+			return -x
+		case x < 1:
+			return x
+		}
+		return x*x - 1
+	}
+	if pp.Order() != 2 || pp.Pieces() != 3 {
+		t.Fatalf("[PPoly] Order %d, %d pieces", pp.Order(), pp.Pieces())
+	}
+	xs := []float64{-2, -1, -0.5, 0, 0.25, 1, 2, 3, 4, 0.5, -1.5}
+	dst := make([]float64, len(xs))
+	pp.EvalInto(dst, xs)
+	for i, x := range xs {
+		if d := math.Abs(pp.At(x) - truth(x)); d > 1e-12 {
+			t.Fatalf("[PPoly] At(%f) = %f, expected %f", x, pp.At(x), truth(x))
+		}
+		if dst[i] != pp.At(x) {
+			t.Fatalf("[PPoly] EvalInto = %f, At = %f at %f", dst[i], pp.At(x), x)
+		}
+	}
+
+	descending := pp.DescendingCoefs()
+	if descending[2][0] != 1 || descending[2][1] != 2 || descending[2][2] != 0 {
+		t.Fatalf("[PPoly] Descending coefficients %v", descending[2])
+	}
+
+	if _, err := NewPiecewisePolynomial([]float64{0, 0, 1}, [][]float64{{1}, {1}}); !errors.Is(err, splineerr.ErrInvalidKnots) {
+		t.Fatalf("[PPoly] Expected ErrInvalidKnots, got %v", err)
+	}
+	if _, err := NewPiecewisePolynomial([]float64{0, 1, 2}, [][]float64{{1}}); !errors.Is(err, splineerr.ErrCoefLength) {
+		t.Fatalf("[PPoly] Expected ErrCoefLength, got %v", err)
+	}
+	if _, err := NewPiecewisePolynomial([]float64{0, 1, 2}, [][]float64{{1, 2}, {1}}); !errors.Is(err, splineerr.ErrCoefLength) {
+		t.Fatalf("[PPoly] Expected ErrCoefLength for ragged pieces, got %v", err)
+	}
+}
+
+func TestPiecewisePolynomialSub(t *testing.T) {
+	// x^2 on [0, 1, 2], and 1 + x on [0, 0.5, 2] in local coordinates
+	p, err := NewPiecewisePolynomial([]float64{0, 1, 2}, [][]float64{{0, 0, 1}, {1, 2, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewPiecewisePolynomial([]float64{0, 0.5, 2}, [][]float64{{1, 1}, {1.5, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := p.Sub(q)
+	if len(d.Breaks) != 4 || d.Order() != 2 {
+		t.Fatalf("[PPoly] Difference on %v of order %d", d.Breaks, d.Order())
+	}
+	for x := -0.5; x <= 2.5; x += 0.01 {
+		if e := math.Abs(d.At(x) - (x*x - 1 - x)); e > 1e-12 {
+			t.Fatalf("[PPoly] Difference at %f is off by %g", x, e)
+		}
+	}
+	// x^2 - x - 1 is smallest at 0.5 and largest at 2 on [0, 2]
+	if x, v := d.Min(0, 2); math.Abs(x-0.5) > 1e-12 || math.Abs(v+1.25) > 1e-12 {
+		t.Fatalf("[PPoly] Min of the difference %f at %f", v, x)
+	}
+	if x, v := d.Max(0, 2); x != 2 || math.Abs(v-1) > 1e-12 {
+		t.Fatalf("[PPoly] Max of the difference %f at %f", v, x)
+	}
+}
+
+func TestPiecewisePolynomialRoots(t *testing.T) {
+	// (x - 0.2)(x - 0.5)(x - 0.9) on [0, 1]
+	cubic := []float64{-0.09, 0.73, -1.6, 1}
+	pp, err := NewPiecewisePolynomial([]float64{0, 1}, [][]float64{cubic})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots := pp.Roots(0)
+	t.Logf("[PPoly] Roots %v", roots)
+	expected := []float64{0.2, 0.5, 0.9}
+	if len(roots) != len(expected) {
+		t.Fatalf("[PPoly] Roots %v, expected %v", roots, expected)
+	}
+	for i, r := range roots {
+		if math.Abs(r-expected[i]) > 1e-12 {
+			t.Fatalf("[PPoly] Roots %v, expected %v", roots, expected)
+		}
+	}
+
+	// Double root where the level is touched, roots at breaks, and a constant piece at the level
+	// whose ends are roots of its neighbours
+	pp, err = NewPiecewisePolynomial([]float64{-1, 0, 1, 2, 3}, [][]float64{
+		{0, -1, 1},
+		{0, 1, -1},
+		{0, 0, 0},
+		{0, 0, 1},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots = pp.Roots(0)
+	t.Logf("[PPoly] Roots %v", roots)
+	expected = []float64{-1, 0, 1, 2}
+	if len(roots) != len(expected) {
+		t.Fatalf("[PPoly] Roots %v, expected %v", roots, expected)
+	}
+	for i, r := range roots {
+		if math.Abs(r-expected[i]) > 1e-12 {
+			t.Fatalf("[PPoly] Roots %v, expected %v", roots, expected)
+		}
+	}
+
+	// A constant is skipped at its own value
+	pp, err = NewPiecewisePolynomial([]float64{0, 1, 2, 3}, [][]float64{{2, 0}, {2, 0}, {2, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roots = pp.Roots(2); len(roots) != 0 {
+		t.Fatalf("[PPoly] Roots %v of a constant at its value, expected none", roots)
+	}
+	// A plateau at the level between a rise and a fall keeps only its ends
+	pp, err = NewPiecewisePolynomial([]float64{0, 1, 2, 3}, [][]float64{{0, 1}, {1, 0}, {1, -1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots = pp.Roots(1)
+	if len(roots) != 2 || roots[0] != 1 || roots[1] != 2 {
+		t.Fatalf("[PPoly] Roots %v at a plateau, expected [1 2]", roots)
+	}
+
+	// Wilkinson-like clustered roots of a degree 8 polynomial
+	c := []float64{1}
+	for _, r := range []float64{0.1, 0.15, 0.2, 0.3, 0.45, 0.6, 0.8, 0.95} {
+		next := make([]float64, len(c)+1)
+		for k, v := range c {
+			next[k+1] += v
+			next[k] -= r * v
+		}
+		c = next
+	}
+	pp, err = NewPiecewisePolynomial([]float64{0, 1}, [][]float64{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+	roots = pp.Roots(0)
+	if len(roots) != 8 {
+		t.Fatalf("[PPoly] %d roots of degree 8: %v", len(roots), roots)
+	}
+	for _, r := range roots {
+		if v := pp.At(r); math.Abs(v) > 1e-15 {
+			t.Fatalf("[PPoly] p(%f) = %g", r, v)
+		}
+	}
+}
+
+func TestPiecewisePolynomialExtrema(t *testing.T) {
+	// (x - 0.2)(x - 0.5)(x - 0.9) on [0, 1], then |x - 1| + its value at 1 as a kink
+	pp, err := NewPiecewisePolynomial([]float64{0, 1, 2}, [][]float64{
+		{-0.09, 0.73, -1.6, 1},
+		{0.04, 1, 0, 0},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	extrema := pp.Extrema()
+	t.Logf("[PPoly] Extrema %v", extrema)
+	// p' = 3x^2 - 3.2x + 0.73
+	d := math.Sqrt(3.2*3.2 - 12*0.73)
+	expected := []Extremum{
+		{X: (3.2 - d) / 6, Kind: Maximum},
+		{X: (3.2 + d) / 6, Kind: Minimum},
+	}
+	if len(extrema) != len(expected) {
+		t.Fatalf("[PPoly] Extrema %v, expected %v", extrema, expected)
+	}
+	for i, e := range extrema {
+		if math.Abs(e.X-expected[i].X) > 1e-12 || e.Kind != expected[i].Kind || e.Value != pp.At(e.X) {
+			t.Fatalf("[PPoly] Extrema %v, expected %v", extrema, expected)
+		}
+	}
+	if inflections := pp.InflectionPoints(); len(inflections) != 1 || math.Abs(inflections[0]-1.6/3) > 1e-12 {
+		t.Fatalf("[PPoly] Inflection points %v", inflections)
+	}
+
+	// A kink turning the direction
+	kink, err := NewPiecewisePolynomial([]float64{-1, 0, 1}, [][]float64{{1, -1}, {0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extrema := kink.Extrema(); len(extrema) != 1 || extrema[0].X != 0 || extrema[0].Kind != Minimum {
+		t.Fatalf("[PPoly] Extrema of |x|: %v", extrema)
+	}
+	// A horizontal inflection is no extremum
+	cube, err := NewPiecewisePolynomial([]float64{-1, 1}, [][]float64{{-1, 3, -3, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extrema := cube.Extrema(); len(extrema) != 0 {
+		t.Fatalf("[PPoly] Extrema of x^3: %v", extrema)
+	}
+
+	for _, c := range []struct {
+		a, b, x, min float64
+	}{
+		{0.3, 2, expected[1].X, pp.At(expected[1].X)},
+		{0, 0.1, 0, -0.09},
+		{0.5, 0.3, 0.5, 0},
+		{-1, 0.1, -1, pp.At(-1)},
+	} {
+		x, v := pp.Min(c.a, c.b)
+		if math.Abs(x-c.x) > 1e-12 || math.Abs(v-c.min) > 1e-12 {
+			t.Fatalf("[PPoly] Min on [%f, %f] is %f at %f, expected %f at %f", c.a, c.b, v, x, c.min, c.x)
+		}
+	}
+	if x, v := pp.Max(0, 2); x != 2 || math.Abs(v-1.04) > 1e-12 {
+		t.Fatalf("[PPoly] Max is %f at %f", v, x)
+	}
+	if x, v := pp.Max(0, 0.9); math.Abs(x-expected[0].X) > 1e-12 || math.Abs(v-pp.At(expected[0].X)) > 1e-12 {
+		t.Fatalf("[PPoly] Max is %f at %f", v, x)
+	}
+}
@@ -0,0 +1,128 @@
+package ppoly
+
+import "math"
+
+// RootTolerance Roots closer than this, relative to the extent of the breaks, are merged
+const RootTolerance = 1e-12
+
+// Roots Abscissae in [Breaks[0], Breaks[len(Breaks)-1]] where p equals level, in increasing order.
+// Each piece is split at the roots of its derivative, isolated recursively the same way,
+// into monotone intervals holding at most one root, which is bracketed and bisected.
+// A piece equal to level throughout has no isolated roots and is skipped,
+// so a constant p has none at its own value. Where a neighbouring piece reaches the level
+// at the shared break, that break is still a root of the neighbour.
+func (p *PiecewisePolynomial) Roots(level float64) []float64 {
+	first, last := p.Breaks[0], p.Breaks[len(p.Breaks)-1]
+	tol := RootTolerance * (last - first + math.Abs(first) + math.Abs(last))
+
+	var roots []float64
+	add := func(x float64) {
+		if len(roots) > 0 && x-roots[len(roots)-1] <= tol {
+			return
+		}
+		roots = append(roots, x)
+	}
+	c := make([]float64, p.Order()+1)
+	for i, coefs := range p.Coefs {
+		copy(c, coefs)
+		c[0] -= level
+		a, h := p.Breaks[i], p.Breaks[i+1]-p.Breaks[i]
+		if degree(c) < 0 {
+			continue
+		}
+		for _, t := range polynomialRoots(c, 0, h) {
+			add(a + t)
+		}
+	}
+	return roots
+}
+
+// Derivative Piecewise polynomial of one order less on the same breaks.
+// The derivative of a piecewise constant is 0.
+func (p *PiecewisePolynomial) Derivative() *PiecewisePolynomial {
+	coefs := make([][]float64, len(p.Coefs))
+	for i, c := range p.Coefs {
+		coefs[i] = derivative(c)
+	}
+	return &PiecewisePolynomial{Breaks: p.Breaks, Coefs: coefs}
+}
+
+// degree Highest power with a nonzero coefficient, -1 for the zero polynomial
+func degree(c []float64) int {
+	n := len(c) - 1
+	for n >= 0 && c[n] == 0 {
+		n--
+	}
+	return n
+}
+
+// derivative Coefficients of the derivative of sum_k c[k] * t^k, at least one
+func derivative(c []float64) []float64 {
+	if len(c) == 1 {
+		return []float64{0}
+	}
+	d := make([]float64, len(c)-1)
+	for k := range d {
+		d[k] = float64(k+1) * c[k+1]
+	}
+	return d
+}
+
+// polynomialRoots Roots of sum_k c[k] * t^k in [lo, hi] in increasing order,
+// assuming the polynomial is not zero. Between consecutive roots of the derivative
+// the polynomial is monotone, so a change of sign brackets exactly one root.
+func polynomialRoots(c []float64, lo, hi float64) []float64 {
+	n := degree(c)
+	c = c[:n+1]
+	switch n {
+	case 0:
+		return nil
+	case 1:
+		if t := -c[0] / c[1]; lo <= t && t <= hi {
+			return []float64{t}
+		}
+		return nil
+	}
+
+	ends := []float64{lo}
+	for _, t := range append(polynomialRoots(derivative(c), lo, hi), hi) {
+		if t > ends[len(ends)-1] {
+			ends = append(ends, t)
+		}
+	}
+	var roots []float64
+	for i := 0; i+1 < len(ends); i++ {
+		a, b := ends[i], ends[i+1]
+		fa, fb := horner(c, a), horner(c, b)
+		switch {
+		case fa == 0:
+			roots = append(roots, a)
+		case fb != 0 && (fa < 0) != (fb < 0):
+			roots = append(roots, bisect(c, a, b, fa))
+		}
+	}
+	if horner(c, hi) == 0 {
+		roots = append(roots, hi)
+	}
+	return roots
+}
+
+// bisect Root of sum_k c[k] * t^k in (a, b) where it changes sign, with fa at a,
+// halving the bracket until it holds no other float64
+func bisect(c []float64, a, b, fa float64) float64 {
+	for {
+		m := a + (b-a)/2
+		if m <= a || m >= b {
+			return m
+		}
+		fm := horner(c, m)
+		if fm == 0 {
+			return m
+		}
+		if (fm < 0) == (fa < 0) {
+			a, fa = m, fm
+		} else {
+			b = m
+		}
+	}
+}
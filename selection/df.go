@@ -0,0 +1,85 @@
+package selection
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+)
+
+// dfIterations Bisection steps on log10(lambda)
+const dfIterations = 100
+
+// dfTolerance Degrees of freedom this close to the ends of DFRange, relative to its upper end, are taken as the ends
+const dfTolerance = 1e-9
+
+// dfExtraLog10 Decades by which the search range of lambda is widened at most to bracket df
+const dfExtraLog10 = 16.0
+
+// LambdaForDF Find lambda whose fit has the given effective degrees of freedom in the closed DFRange of s.
+// The trace of the hat matrix decreases from the upper to the lower end of DFRange as lambda grows,
+// so df is searched by bisection on log10(lambda), widening the range of Minimize until it brackets df.
+// The upper end is the unpenalized fit of lambda = 0 if it can be solved. The lower end is only
+// approached as lambda grows to infinity, so df beyond the fit at the largest lambda gets that fit.
+func LambdaForDF(s Smoother, df float64) (float64, Fit, error) {
+	minDF, maxDF := s.DFRange()
+	tol := dfTolerance * math.Max(1, maxDF)
+	if !(minDF-tol <= df && df <= maxDF+tol) {
+		return 0, Fit{}, fmt.Errorf("[Selection] Degrees of freedom %f out of [%f, %f]: %w", df, minDF, maxDF, splineerr.ErrInvalidArgument)
+	}
+	if df >= maxDF-tol {
+		if fit, err := s.Smooth(0); err == nil {
+			return 0, fit, nil
+		}
+	}
+
+	scale := s.Scale()
+	lo := math.Log10(scale) + minLog10
+	hi := math.Log10(scale) + maxLog10
+
+	fitLo, err := s.Smooth(math.Pow(10, lo))
+	if err != nil {
+		return 0, Fit{}, err
+	}
+	// Stop widening where the system becomes singular
+	for floor := lo - dfExtraLog10; fitLo.DF() < df && lo > floor; lo-- {
+		f, err := s.Smooth(math.Pow(10, lo-1))
+		if err != nil {
+			break
+		}
+		fitLo = f
+	}
+	if df >= fitLo.DF() {
+		return fitLo.Lambda, fitLo, nil
+	}
+	fitHi, err := s.Smooth(math.Pow(10, hi))
+	if err != nil {
+		return 0, Fit{}, err
+	}
+	for ceiling := hi + dfExtraLog10; fitHi.DF() > df && hi < ceiling; hi++ {
+		f, err := s.Smooth(math.Pow(10, hi+1))
+		if err != nil {
+			break
+		}
+		fitHi = f
+	}
+	if df <= fitHi.DF() {
+		return fitHi.Lambda, fitHi, nil
+	}
+
+	fit := fitLo
+	for i := 0; i < dfIterations && hi-lo > goldenTol*goldenTol; i++ {
+		mid := (lo + hi) / 2
+		f, err := s.Smooth(math.Pow(10, mid))
+		if err != nil {
+			return 0, Fit{}, err
+		}
+		fit = f
+		if f.DF() > df {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return fit.Lambda, fit, nil
+}
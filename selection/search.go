@@ -0,0 +1,145 @@
+package selection
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+)
+
+const (
+	// Lambda is searched on [Scale * 10^minLog10, Scale * 10^maxLog10]
+	minLog10 = -8.0
+	maxLog10 = 6.0
+	// gridStep Step of the initial grid on log10(lambda)
+	gridStep = 0.5
+	// goldenTol Tolerance of the golden-section search on log10(lambda)
+	goldenTol = 1e-4
+)
+
+// Point A point of the criterion curve
+type Point struct {
+	Lambda float64
+	Score  float64
+}
+
+// Result Result of a lambda selection
+type Result struct {
+	Lambda float64
+	Score  float64
+	Fit    Fit
+	// Curve Every evaluated (lambda, score), sorted by lambda
+	Curve []Point
+}
+
+// Minimize Find lambda minimizing the criterion over a range determined by s.Scale()
+func Minimize(s Smoother, criterion Criterion) (Result, error) {
+	scale := s.Scale()
+	return MinimizeRange(s, criterion, scale*math.Pow(10, minLog10), scale*math.Pow(10, maxLog10))
+}
+
+// MinimizeRange Find lambda in [lo, hi] minimizing the criterion.
+// The criterion is evaluated on a grid of log10(lambda), then the best grid point
+// is refined by a golden-section search on log10(lambda) between its neighbours.
+// Lambdas at which the smoother fails score +Inf; if it fails everywhere, the last error is returned.
+func MinimizeRange(s Smoother, criterion Criterion, lo, hi float64) (Result, error) {
+	if lo <= 0 || hi <= lo || math.IsInf(hi, 0) || math.IsNaN(lo) || math.IsNaN(hi) {
+		return Result{}, fmt.Errorf("[Selection] Invalid range of lambda [%g, %g]: %w", lo, hi, splineerr.ErrInvalidArgument)
+	}
+	var curve []Point
+	var lastErr error
+	fits := make(map[float64]Fit)
+	score := func(logLambda float64) float64 {
+		lambda := math.Pow(10, logLambda)
+		fit, err := s.Smooth(lambda)
+		v := math.Inf(1)
+		if err != nil {
+			lastErr = err
+		} else {
+			v = criterion(fit)
+			if math.IsNaN(v) {
+				v = math.Inf(1)
+			}
+			fits[logLambda] = fit
+		}
+		curve = append(curve, Point{Lambda: lambda, Score: v})
+		return v
+	}
+
+	logLo, logHi := math.Log10(lo), math.Log10(hi)
+	steps := int(math.Ceil((logHi - logLo) / gridStep))
+	if steps < 2 {
+		steps = 2
+	}
+	grid := make([]float64, steps+1)
+	scores := make([]float64, steps+1)
+	best := 0
+	for i := range grid {
+		grid[i] = logLo + (logHi-logLo)*float64(i)/float64(steps)
+		scores[i] = score(grid[i])
+		if scores[i] < scores[best] {
+			best = i
+		}
+	}
+
+	bestLog, bestScore := grid[best], scores[best]
+	if math.IsInf(bestScore, 1) {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("[Selection] Criterion is infinite on [%g, %g]: %w", lo, hi, splineerr.ErrInvalidArgument)
+		}
+		return Result{}, lastErr
+	}
+	a := grid[maxInt(best-1, 0)]
+	b := grid[minInt(best+1, steps)]
+	if x, v := goldenSection(score, a, b); v < bestScore {
+		bestLog, bestScore = x, v
+	}
+
+	sort.Slice(curve, func(i, j int) bool {
+		return curve[i].Lambda < curve[j].Lambda
+	})
+	return Result{
+		Lambda: math.Pow(10, bestLog),
+		Score:  bestScore,
+		Fit:    fits[bestLog],
+		Curve:  curve,
+	}, nil
+}
+
+// goldenSection Minimizer of f on [a, b], assuming f is unimodal there
+func goldenSection(f func(float64) float64, a, b float64) (float64, float64) {
+	invPhi := (math.Sqrt(5) - 1) / 2
+	c := b - invPhi*(b-a)
+	d := a + invPhi*(b-a)
+	fc, fd := f(c), f(d)
+	for b-a > goldenTol {
+		if fc < fd {
+			b, d, fd = d, c, fc
+			c = b - invPhi*(b-a)
+			fc = f(c)
+		} else {
+			a, c, fc = c, d, fd
+			d = a + invPhi*(b-a)
+			fd = f(d)
+		}
+	}
+	if fc < fd {
+		return c, fc
+	}
+	return d, fd
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,264 @@
+// Package selection Automatic choice of the smoothing parameter lambda
+// of penalized regression splines
+//     minimize sum_i w_i * (y_i - (B * c)_i)^2 + lambda * c^T * Omega * c
+// by generalized cross-validation, leave-one-out cross-validation or REML.
+package selection
+
+import (
+	"math"
+
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+	"gonum.org/v1/gonum/mat"
+)
+
+// Fit Result of a penalized fit at a fixed lambda
+type Fit struct {
+	Lambda float64
+	// Coefs Fitted coefficients c
+	Coefs []float64
+	// Residuals y_i - (B * c)_i
+	Residuals []float64
+	// Weights w_i of the observations, nil if all are 1
+	Weights []float64
+	// Leverage Diagonal of the hat matrix H = B * (B^T * W * B + lambda * Omega)^-1 * B^T * W
+	Leverage []float64
+	// Penalty c^T * Omega * c, not scaled by lambda
+	Penalty float64
+	// LogDet log det(B^T * W * B + lambda * Omega)
+	LogDet float64
+	// Dim Number of coefficients
+	Dim int
+	// PenaltyRank Rank of Omega
+	PenaltyRank int
+}
+
+// DF Effective degrees of freedom, trace of the hat matrix
+func (f Fit) DF() float64 {
+	var df float64
+	for _, h := range f.Leverage {
+		df += h
+	}
+	return df
+}
+
+// RSS Weighted residual sum of squares
+func (f Fit) RSS() float64 {
+	var rss float64
+	for i, r := range f.Residuals {
+		rss += f.weight(i) * r * r
+	}
+	return rss
+}
+
+// N Number of observations with positive weight
+func (f Fit) N() int {
+	if f.Weights == nil {
+		return len(f.Residuals)
+	}
+	var n int
+	for _, w := range f.Weights {
+		if w > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func (f Fit) weight(i int) float64 {
+	if f.Weights == nil {
+		return 1
+	}
+	return f.Weights[i]
+}
+
+// Smoother A linear smoother which can be fitted at any lambda
+type Smoother interface {
+	// Smooth Fit at lambda
+	Smooth(lambda float64) (Fit, error)
+	// Scale Typical magnitude of lambda, where the fit and the penalty are balanced
+	Scale() float64
+	// DFRange Degrees of freedom of the limiting fits as lambda grows to infinity and shrinks to 0
+	DFRange() (min, max float64)
+}
+
+// PenalizedLeastSquares Smoother of observations y with weights w on the basis matrix B with penalty Omega
+type PenalizedLeastSquares struct {
+	basis   *mat.Dense
+	penalty *mat.Dense
+	rank    int
+	y       *mat.VecDense
+	weights []float64
+
+	btb *mat.Dense
+	bty *mat.VecDense
+}
+
+// NewPenalizedLeastSquares A new pointer of PenalizedLeastSquares
+//     basis:   B_ij = B_j(x_i)
+//     penalty: Omega, rank is its rank
+// y may be nil when only the leverage is needed.
+// weights may be nil if all are 1.
+func NewPenalizedLeastSquares(basis, penalty *mat.Dense, rank int, y, weights []float64) *PenalizedLeastSquares {
+	n, _ := basis.Dims()
+	if y == nil {
+		y = make([]float64, n)
+	}
+	Y := mat.NewVecDense(len(y), y)
+
+	// WB = W * B
+	WB := mat.DenseCopyOf(basis)
+	if weights != nil {
+		WB.Apply(func(i, j int, v float64) float64 {
+			return weights[i] * v
+		}, WB)
+	}
+	var btb mat.Dense
+	btb.Mul(basis.T(), WB)
+	var bty mat.VecDense
+	bty.MulVec(WB.T(), Y)
+	return &PenalizedLeastSquares{
+		basis:   basis,
+		penalty: penalty,
+		rank:    rank,
+		y:       Y,
+		weights: weights,
+		btb:     &btb,
+		bty:     &bty,
+	}
+}
+
+// Scale tr(B^T * W * B) / tr(Omega)
+func (p *PenalizedLeastSquares) Scale() float64 {
+	t := mat.Trace(p.penalty)
+	if t <= 0 {
+		return 1
+	}
+	return mat.Trace(p.btb) / t
+}
+
+// DFRange Dimension of the null space of Omega, and the number of coefficients
+// or of the observations with positive weight if fewer
+func (p *PenalizedLeastSquares) DFRange() (float64, float64) {
+	n, dim := p.basis.Dims()
+	if p.weights != nil {
+		n = 0
+		for _, w := range p.weights {
+			if w > 0 {
+				n++
+			}
+		}
+	}
+	max := dim
+	if n < max {
+		max = n
+	}
+	return float64(dim - p.rank), float64(max)
+}
+
+// Smooth Fit at lambda. Returns *splineerr.SingularSystemError if the system could not be solved.
+func (p *PenalizedLeastSquares) Smooth(lambda float64) (Fit, error) {
+	n, dim := p.basis.Dims()
+
+	var A mat.Dense
+	A.Scale(lambda, p.penalty)
+	A.Add(&A, p.btb)
+	ASym := mat.NewSymDense(dim, A.RawMatrix().Data)
+
+	var chol mat.Cholesky
+	if ok := chol.Factorize(ASym); !ok {
+		return Fit{}, &splineerr.SingularSystemError{Cond: mat.Cond(ASym, 1)}
+	}
+
+	var coefs mat.VecDense
+	if err := chol.SolveVecTo(&coefs, p.bty); err != nil {
+		if _, ok := err.(mat.Condition); !ok {
+			return Fit{}, &splineerr.SingularSystemError{Cond: chol.Cond()}
+		}
+	}
+	// S = A^-1 * B^T, so that H = B * S * W
+	var S mat.Dense
+	if err := chol.SolveTo(&S, p.basis.T()); err != nil {
+		if _, ok := err.(mat.Condition); !ok {
+			return Fit{}, &splineerr.SingularSystemError{Cond: chol.Cond()}
+		}
+	}
+
+	var fitted mat.VecDense
+	fitted.MulVec(p.basis, &coefs)
+
+	fit := Fit{
+		Lambda:      lambda,
+		Coefs:       make([]float64, dim),
+		Residuals:   make([]float64, n),
+		Weights:     p.weights,
+		Leverage:    make([]float64, n),
+		Penalty:     mat.Inner(&coefs, p.penalty, &coefs),
+		LogDet:      chol.LogDet(),
+		Dim:         dim,
+		PenaltyRank: p.rank,
+	}
+	for j := 0; j < dim; j++ {
+		fit.Coefs[j] = coefs.AtVec(j)
+	}
+	for i := 0; i < n; i++ {
+		fit.Residuals[i] = p.y.AtVec(i) - fitted.AtVec(i)
+		var h float64
+		for j := 0; j < dim; j++ {
+			h += p.basis.At(i, j) * S.At(j, i)
+		}
+		fit.Leverage[i] = fit.weight(i) * h
+	}
+	return fit, nil
+}
+
+// Criterion Score of a fit; smaller is better
+type Criterion func(fit Fit) float64
+
+// GCV Generalized cross-validation
+//     (RSS / n) / (1 - tr(H) / n)^2
+// where n is the number of observations with positive weight.
+func GCV(fit Fit) float64 {
+	n := float64(fit.N())
+	d := 1 - fit.DF()/n
+	if d <= 0 {
+		return math.Inf(1)
+	}
+	return fit.RSS() / n / (d * d)
+}
+
+// LOOCV Exact leave-one-out cross-validation
+//     (1 / n) * sum_i w_i * (r_i / (1 - H_ii))^2
+// where n is the number of observations with positive weight.
+func LOOCV(fit Fit) float64 {
+	var cv float64
+	for i, r := range fit.Residuals {
+		w := fit.weight(i)
+		if w == 0 {
+			continue
+		}
+		d := 1 - fit.Leverage[i]
+		if d <= 0 {
+			return math.Inf(1)
+		}
+		cv += w * (r / d) * (r / d)
+	}
+	return cv / float64(fit.N())
+}
+
+// REML Restricted maximum likelihood with the error variance profiled out.
+// Returns -2 * log-likelihood up to a constant not depending on lambda:
+//     (n - m) * log((RSS + lambda * c^T * Omega * c) / (n - m)) + log det(B^T * W * B + lambda * Omega) - r * log(lambda)
+// where n is the number of observations with positive weight,
+// r is the rank of Omega and m = Dim - r is the dimension of its null space.
+func REML(fit Fit) float64 {
+	n := float64(fit.N())
+	m := float64(fit.Dim - fit.PenaltyRank)
+	if n <= m || fit.Lambda <= 0 {
+		return math.Inf(1)
+	}
+	s := (fit.RSS() + fit.Lambda*fit.Penalty) / (n - m)
+	if s <= 0 {
+		return math.Inf(-1)
+	}
+	return (n-m)*math.Log(s) + fit.LogDet - float64(fit.PenaltyRank)*math.Log(fit.Lambda)
+}
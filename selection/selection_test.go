@@ -0,0 +1,143 @@
+package selection
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+	"gonum.org/v1/gonum/mat"
+)
+
+// polynomialSmoother Penalized polynomial regression, penalizing coefficients above degree 1
+func polynomialSmoother(x, y []float64, degree int) *PenalizedLeastSquares {
+	B := mat.NewDense(len(x), degree+1, nil)
+	for i := range x {
+		for j := 0; j <= degree; j++ {
+			B.Set(i, j, math.Pow(x[i], float64(j)))
+		}
+	}
+	P := mat.NewDense(degree+1, degree+1, nil)
+	for j := 2; j <= degree; j++ {
+		P.Set(j, j, 1)
+	}
+	return NewPenalizedLeastSquares(B, P, degree-1, y, nil)
+}
+
+func testData(n int) ([]float64, []float64) {
+	rnd := rand.New(rand.NewSource(1))
+	x := make([]float64, n)
+	y := make([]float64, n)
+	for i := range x {
+		x[i] = float64(i) / float64(n-1)
+		y[i] = math.Sin(3*x[i]) + 0.1*rnd.NormFloat64()
+	}
+	return x, y
+}
+
+func TestLOOCVIsExact(t *testing.T) {
+	const lambda = 0.1
+	x, y := testData(30)
+	fit, err := polynomialSmoother(x, y, 5).Smooth(lambda)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cv float64
+	for i := range x {
+		xi := append(append([]float64{}, x[:i]...), x[i+1:]...)
+		yi := append(append([]float64{}, y[:i]...), y[i+1:]...)
+		loo, _ := polynomialSmoother(xi, yi, 5).Smooth(lambda)
+		var pred float64
+		for j, c := range loo.Coefs {
+			pred += c * math.Pow(x[i], float64(j))
+		}
+		cv += (y[i] - pred) * (y[i] - pred)
+	}
+	cv /= float64(len(x))
+
+	if math.Abs(cv-LOOCV(fit)) > 1e-10 {
+		t.Fatalf("[Selection] LOOCV = %f, brute force = %f", LOOCV(fit), cv)
+	}
+}
+
+func TestMinimize(t *testing.T) {
+	x, y := testData(100)
+	s := polynomialSmoother(x, y, 6)
+	for name, criterion := range map[string]Criterion{"GCV": GCV, "LOOCV": LOOCV, "REML": REML} {
+		result, err := Minimize(s, criterion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, p := range result.Curve {
+			if p.Score < result.Score {
+				t.Fatalf("[Selection] %s: score %f at %f is smaller than the minimum %f", name, p.Score, p.Lambda, result.Score)
+			}
+		}
+		df := result.Fit.DF()
+		if df <= 2 || df >= 7 {
+			t.Fatalf("[Selection] %s: effective degrees of freedom %f out of (2, 7)", name, df)
+		}
+		t.Logf("[Selection] %s: lambda = %g, df = %f, score = %f", name, result.Lambda, df, result.Score)
+	}
+}
+
+func TestLambdaForDF(t *testing.T) {
+	x, y := testData(50)
+	s := polynomialSmoother(x, y, 6)
+	for _, df := range []float64{2.5, 3, 4.5, 6} {
+		lambda, fit, err := LambdaForDF(s, df)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(fit.DF()-df) > 1e-6 {
+			t.Fatalf("[Selection] df = %f at lambda = %g, expected %f", fit.DF(), lambda, df)
+		}
+	}
+
+	// The ends of DFRange give the limiting fits
+	minDF, maxDF := s.DFRange()
+	if minDF != 2 || maxDF != 7 {
+		t.Fatalf("[Selection] DFRange [%f, %f], expected [2, 7]", minDF, maxDF)
+	}
+	for _, df := range []float64{minDF, maxDF} {
+		_, fit, err := LambdaForDF(s, df)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(fit.DF()-df) > 1e-3 {
+			t.Fatalf("[Selection] df = %f at the end %f of DFRange", fit.DF(), df)
+		}
+	}
+	for _, df := range []float64{minDF - 0.01, maxDF + 0.01} {
+		if _, _, err := LambdaForDF(s, df); !errors.Is(err, splineerr.ErrInvalidArgument) {
+			t.Fatalf("[Selection] Expected ErrInvalidArgument for df %f, got %v", df, err)
+		}
+	}
+}
+
+func TestWeightsAggregateDuplicates(t *testing.T) {
+	const lambda = 0.01
+	x, y := testData(20)
+	// Observation 3 twice equals observation 3 with weight 2
+	xd := append(append([]float64{}, x...), x[3])
+	yd := append(append([]float64{}, y...), y[3])
+	w := make([]float64, len(x))
+	for i := range w {
+		w[i] = 1
+	}
+	w[3] = 2
+
+	duplicated, _ := polynomialSmoother(xd, yd, 5).Smooth(lambda)
+	s := polynomialSmoother(x, y, 5)
+	weighted, _ := NewPenalizedLeastSquares(s.basis, s.penalty, s.rank, y, w).Smooth(lambda)
+	for j := range weighted.Coefs {
+		if math.Abs(weighted.Coefs[j]-duplicated.Coefs[j]) > 1e-8 {
+			t.Fatalf("[Selection] Coefficient %d: weighted %f, duplicated %f", j, weighted.Coefs[j], duplicated.Coefs[j])
+		}
+	}
+	if math.Abs(weighted.RSS()-duplicated.RSS()) > 1e-10 {
+		t.Fatalf("[Selection] RSS: weighted %f, duplicated %f", weighted.RSS(), duplicated.RSS())
+	}
+}
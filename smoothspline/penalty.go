@@ -0,0 +1,112 @@
+package smoothspline
+
+import (
+	"github.com/helloworldpark/gonaturalspline/bspline"
+	"github.com/helloworldpark/gonaturalspline/knot"
+	"gonum.org/v1/gonum/integrate/quad"
+	"gonum.org/v1/gonum/mat"
+)
+
+// penaltyMatrix Integrated squared second derivative penalty of the B-Spline basis
+//     Omega_jk = integral of B_j''(x) * B_k''(x) dx over [k_0, k_(count-1)]
+func penaltyMatrix(spline bspline.BSpline) (*mat.Dense, error) {
+	return derivativeGramMatrix(spline.Knots(), spline.Order(), 2)
+}
+
+// derivativeGramMatrix Gram matrix of the deriv-th derivatives of the B-Spline basis
+//     G_jk = integral of B_j^(deriv)(x) * B_k^(deriv)(x) dx over [k_0, k_(count-1)]
+// B_j^(deriv) is written as a B-Spline of order-deriv on the same knots, so
+//     G = D^T * G' * D
+// where D differences the coefficients deriv times and G' is the Gram matrix of the order-deriv basis.
+func derivativeGramMatrix(knots knot.Knot, order, deriv int) (*mat.Dense, error) {
+	n := knots.Count() + order
+
+	gram := mat.NewDense(n, n, nil)
+	if order < deriv {
+		// Derivative vanishes almost everywhere
+		return gram, nil
+	}
+
+	D := differenceMatrix(knots, order, deriv)
+	G, err := gramMatrix(knots, order-deriv)
+	if err != nil {
+		return nil, err
+	}
+
+	var GD mat.Dense
+	GD.Mul(G, D)
+	gram.Mul(D.T(), &GD)
+	return gram, nil
+}
+
+// differenceMatrix Maps coefficients of a B-Spline of the given order to the coefficients
+// of its deriv-th derivative, a B-Spline of order-deriv on the same knots.
+// Uses the standard recursion
+//     d_j = q * (c_j - c_(j-1)) / (t_(j+q) - t_j)
+// where q is the order being differentiated and t_i = knots.At(i - order).
+func differenceMatrix(knots knot.Knot, order, deriv int) *mat.Dense {
+	n := knots.Count() + order
+	t := func(i int) float64 {
+		return knots.At(i - order)
+	}
+
+	D := mat.NewDense(n, n, nil)
+	for i := 0; i < n; i++ {
+		D.Set(i, i, 1)
+	}
+	for k := 1; k <= deriv; k++ {
+		rows := n - k
+		step := mat.NewDense(rows, rows+1, nil)
+		for r := 0; r < rows; r++ {
+			width := t(r+order+1) - t(r+k)
+			if width == 0 {
+				continue
+			}
+			v := float64(order-k+1) / width
+			step.Set(r, r, -v)
+			step.Set(r, r+1, v)
+		}
+		var next mat.Dense
+		next.Mul(step, D)
+		D = &next
+	}
+	return D
+}
+
+// gramMatrix Gram matrix of the B-Spline basis of the given order over [k_0, k_(count-1)]
+//     G_jk = integral of B_j(x) * B_k(x) dx
+// Integrated exactly by Gauss-Legendre quadrature on each knot span.
+func gramMatrix(knots knot.Knot, order int) (*mat.Dense, error) {
+	n := knots.Count() + order
+	spline, err := bspline.NewBSplineSimple(order, knots, make([]float64, n))
+	if err != nil {
+		return nil, err
+	}
+
+	// order+1 nodes integrate polynomials up to degree 2*order+1 exactly
+	nodes := make([]float64, order+1)
+	weights := make([]float64, order+1)
+	values := make([]float64, order+1)
+
+	G := mat.NewDense(n, n, nil)
+	for i := 0; i < knots.Count()-1; i++ {
+		a, b := knots.At(i), knots.At(i+1)
+		if a == b {
+			continue
+		}
+		quad.Legendre{}.FixedLocations(nodes, weights, a, b)
+		for q := range nodes {
+			// Only B_i, ... , B_(i+order) are nonzero on [k_i, k_(i+1))
+			for m := 0; m <= order; m++ {
+				values[m] = spline.GetBSpline(i + m).Evaluate(nodes[q])
+			}
+			for m := 0; m <= order; m++ {
+				for l := 0; l <= order; l++ {
+					v := G.At(i+m, i+l) + weights[q]*values[m]*values[l]
+					G.Set(i+m, i+l, v)
+				}
+			}
+		}
+	}
+	return G, nil
+}
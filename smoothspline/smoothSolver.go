@@ -2,41 +2,207 @@ package smoothspline
 
 import (
 	"fmt"
+	"math"
 
 	"github.com/helloworldpark/gonaturalspline/bspline"
+	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/selection"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
 	"gonum.org/v1/gonum/mat"
 )
 
+// SmoothSolver Penalized B-Spline smoothing
+// Minimizes
+//     sum_i w_i * (y_i - f(x_i))^2 + lambda * integral of f''(x)^2 dx
+// over f = sum_j c_j * B_j, where B_j are the basis functions of the given B-Spline.
+// The penalty is integrated over [k_0, k_(count-1)] of the knots.
+// For a bspline.PeriodicBSpline, B_j are its periodic basis functions,
+// so that the fit is as smooth across the period as anywhere else.
 type SmoothSolver struct {
 	bSpline        bspline.BSpline
 	bRegressionMat *mat.Dense
 	bSolvedMat     *mat.Dense
 	bPenaltyMat    *mat.Dense // scale by lambda at calculation
 	lambda         float64
+
+	// basis Indices of the basis functions which are not identically zero on [k_0, k_(count-1)]
+	basis []int
+	// x, y Observations of the last fit
+	x, y []float64
+	// w Weights of the observations. If nil, all weights are 1.
+	w []float64
 }
 
-func NewSmoothSolver(spline bspline.BSpline, lambda float64) *SmoothSolver {
+// NewSmoothSolver A new pointer of SmoothSolver fitting the coefficients of spline
+func NewSmoothSolver(spline bspline.BSpline, lambda float64) (*SmoothSolver, error) {
+	if spline == nil {
+		return nil, fmt.Errorf("[SmoothSolver] No B-Spline was given: %w", splineerr.ErrInvalidArgument)
+	}
+	if lambda < 0 || math.IsNaN(lambda) {
+		return nil, fmt.Errorf("[SmoothSolver] Lambda %f: %w", lambda, splineerr.ErrInvalidArgument)
+	}
 	return &SmoothSolver{
 		bSpline: spline,
 		lambda:  lambda,
+	}, nil
+}
+
+// SetWeights Set the weights w_i of the observations of the following fits.
+// A weight of 0 masks the observation. If nil, all weights are 1.
+func (solver *SmoothSolver) SetWeights(w []float64) error {
+	for _, v := range w {
+		if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("[SmoothSolver] Weight %f: %w", v, splineerr.ErrInvalidWeights)
+		}
+	}
+	solver.w = w
+	return nil
+}
+
+// Fit Fit the B-Spline to the observations (x_i, y_i).
+// The coefficients are written back to the B-Spline.
+// Coefficients of basis functions vanishing on [k_0, k_(count-1)] are set to 0.
+func (solver *SmoothSolver) Fit(x, y []float64) error {
+	if err := solver.prepare(x, y); err != nil {
+		return err
+	}
+	if err := solver.calcCholesky(); err != nil {
+		return err
+	}
+	solver.x, solver.y = x, y
+
+	Y := mat.NewVecDense(len(y), y)
+	var coefs mat.VecDense
+	coefs.MulVec(solver.bSolvedMat, Y)
+
+	for j := 0; j < solver.bSpline.Knots().Count()+solver.bSpline.Order(); j++ {
+		solver.bSpline.SetCoef(j, 0)
+	}
+	for i, j := range solver.basis {
+		solver.bSpline.SetCoef(j, coefs.AtVec(i))
+	}
+	return nil
+}
+
+// SelectLambda Choose lambda minimizing the criterion, then fit the B-Spline with it
+func (solver *SmoothSolver) SelectLambda(x, y []float64, criterion selection.Criterion) (selection.Result, error) {
+	if err := solver.prepare(x, y); err != nil {
+		return selection.Result{}, err
+	}
+	pls := selection.NewPenalizedLeastSquares(solver.bRegressionMat, solver.bPenaltyMat, solver.penaltyRank(), y, solver.w)
+	result, err := selection.Minimize(pls, criterion)
+	if err != nil {
+		return selection.Result{}, err
+	}
+	solver.lambda = result.Lambda
+	return result, solver.Fit(x, y)
+}
+
+// SolveDF Refit the observations of the last fit with lambda whose smoother matrix has trace df,
+// and return the lambda. df should be in [2, number of basis functions].
+func (solver *SmoothSolver) SolveDF(df float64) (float64, error) {
+	if solver.x == nil {
+		return 0, fmt.Errorf("[SmoothSolver] SolveDF needs observations, call Fit first: %w", splineerr.ErrNotFitted)
+	}
+	if err := solver.prepare(solver.x, solver.y); err != nil {
+		return 0, err
+	}
+	pls := selection.NewPenalizedLeastSquares(solver.bRegressionMat, solver.bPenaltyMat, solver.penaltyRank(), nil, solver.w)
+	lambda, _, err := selection.LambdaForDF(pls, df)
+	if err != nil {
+		return 0, err
+	}
+	solver.lambda = lambda
+	return lambda, solver.Fit(solver.x, solver.y)
+}
+
+// prepare Validate the observations and build the regression and penalty matrices
+func (solver *SmoothSolver) prepare(x, y []float64) error {
+	if len(x) != len(y) {
+		return fmt.Errorf("[SmoothSolver] %d abscissae, %d observations: %w", len(x), len(y), splineerr.ErrDataLength)
+	}
+	if solver.w != nil && len(solver.w) != len(x) {
+		return fmt.Errorf("[SmoothSolver] %d abscissae, %d weights: %w", len(x), len(solver.w), splineerr.ErrDataLength)
+	}
+	solver.calcBasis()
+	solver.calcRegressionMatrix(x)
+	return solver.calcPenaltyMatrix()
+}
+
+// Lambda Smoothing parameter
+func (solver *SmoothSolver) Lambda() float64 {
+	return solver.lambda
+}
+
+func (solver *SmoothSolver) calcBasis() {
+	if periodic, ok := solver.bSpline.(bspline.PeriodicBSpline); ok {
+		solver.basis = solver.basis[:0]
+		for j := 0; j < periodic.CoefCount(); j++ {
+			solver.basis = append(solver.basis, j)
+		}
+		return
 	}
+	solver.basis = supportedBasis(solver.bSpline)
 }
 
-func (solver *SmoothSolver) calcRegressionMatrix() {
+// supportedBasis Indices of the basis functions which are not identically zero on [k_0, k_(count-1)]
+func supportedBasis(spline bspline.BSpline) []int {
+	order := spline.Order()
+	knots := spline.Knots()
+	start, end := knots.At(0), knots.At(knots.Count()-1)
+	var basis []int
+	for j := 0; j < knots.Count()+order; j++ {
+		// B_j is supported on [k_(j-order), k_(j+1)]
+		if knots.At(j-order) < end && knots.At(j+1) > start {
+			basis = append(basis, j)
+		}
+	}
+	return basis
+}
+
+// penaltyRank Rank of the penalty matrix: only piecewise linear functions are not penalized.
+// They are linear unless an interior knot of multiplicity >= order allows a kink,
+// or a knot of multiplicity > order a jump.
+func (solver *SmoothSolver) penaltyRank() int {
 	order := solver.bSpline.Order()
-	N := solver.bSpline.Knots().Count() / 3
-
-	B := mat.NewDense(N, N+order+1, nil)
-	for i := 0; i < N; i++ {
-		x := solver.bSpline.Knots().At(i * 3)
-		for j := 0; j < N+order+1; j++ {
-			v := solver.bSpline.GetBSpline(j).Evaluate(x)
-			B.Set(i, j, v)
+	if order < 2 || len(solver.basis) < 2 {
+		return 0
+	}
+	if _, ok := solver.bSpline.(bspline.PeriodicBSpline); ok {
+		// Only constants are periodic and linear
+		return len(solver.basis) - 1
+	}
+	knots := solver.bSpline.Knots()
+	null := 2
+	for i := 1; i < knots.Count()-1; i++ {
+		x := knots.At(i)
+		if x == knots.At(i-1) || x == knots.At(knots.Count()-1) {
+			continue
+		}
+		if m := knot.Multiplicity(knots, x); m >= order+1 {
+			null += 2
+		} else if m >= order {
+			null++
+		}
+	}
+	if null > len(solver.basis) {
+		return 0
+	}
+	return len(solver.basis) - null
+}
+
+func (solver *SmoothSolver) calcRegressionMatrix(x []float64) {
+	B := mat.NewDense(len(x), len(solver.basis), nil)
+	for i := range x {
+		for c, j := range solver.basis {
+			v := solver.bSpline.GetBSpline(j).Evaluate(x[i])
+			B.Set(i, c, v)
 		}
 	}
 	solver.bRegressionMat = B
 }
 
+// RegressionMatrix Copy of the matrix B_ij = B_j(x_i) of the last fit
 func (solver *SmoothSolver) RegressionMatrix() *mat.Dense {
 	if solver.bRegressionMat == nil {
 		return nil
@@ -46,40 +212,89 @@ func (solver *SmoothSolver) RegressionMatrix() *mat.Dense {
 	return regMat
 }
 
-func (solver *SmoothSolver) calcCholesky() {
-	regMat := solver.bRegressionMat
+func (solver *SmoothSolver) calcPenaltyMatrix() error {
+	omega, err := penaltyMatrix(solver.bSpline)
+	if err != nil {
+		return err
+	}
+	n := len(solver.basis)
+	P := mat.NewDense(n, n, nil)
+	if _, ok := solver.bSpline.(bspline.PeriodicBSpline); ok {
+		// Sum over the coefficients tied to each periodic basis function
+		rows, _ := omega.Dims()
+		for j := 0; j < rows; j++ {
+			for k := 0; k < rows; k++ {
+				P.Set(j%n, k%n, P.At(j%n, k%n)+omega.At(j, k))
+			}
+		}
+		solver.bPenaltyMat = P
+		return nil
+	}
+	for r, j := range solver.basis {
+		for c, k := range solver.basis {
+			P.Set(r, c, omega.At(j, k))
+		}
+	}
+	solver.bPenaltyMat = P
+	return nil
+}
+
+// PenaltyMatrix Copy of the penalty matrix of the last fit, not scaled by lambda
+func (solver *SmoothSolver) PenaltyMatrix() *mat.Dense {
+	if solver.bPenaltyMat == nil {
+		return nil
+	}
+	penMat := mat.NewDense(solver.bPenaltyMat.RawMatrix().Rows, solver.bPenaltyMat.RawMatrix().Cols, nil)
+	_, _ = penMat.Copy(solver.bPenaltyMat)
+	return penMat
+}
+
+// calcCholesky Solves (B^T * W * B + lambda * Omega) * S = B^T * W,
+// so that the coefficients are S * y.
+func (solver *SmoothSolver) calcCholesky() error {
+	solved, err := solvePenalized(solver.bRegressionMat, solver.bPenaltyMat, solver.lambda, solver.w)
+	if err != nil {
+		return err
+	}
+	solver.bSolvedMat = solved
+	return nil
+}
+
+// solvePenalized S solving (B^T * W * B + lambda * Omega) * S = B^T * W for the regression matrix B,
+// the penalty Omega, if any, and the weights w, all 1 if nil
+func solvePenalized(regMat, penaltyMat *mat.Dense, lambda float64, w []float64) (*mat.Dense, error) {
+	weighted := regMat
+	if w != nil {
+		weighted = mat.DenseCopyOf(regMat)
+		weighted.Apply(func(i, j int, v float64) float64 {
+			return w[i] * v
+		}, weighted)
+	}
 	cols := regMat.RawMatrix().Cols
 	btb := mat.NewDense(cols, cols, nil)
-	btb.Mul(regMat.T(), regMat)
-	if solver.bPenaltyMat != nil {
-		btb.Add(btb, solver.bPenaltyMat)
+	btb.Mul(regMat.T(), weighted)
+	if penaltyMat != nil {
+		var penalty mat.Dense
+		penalty.Scale(lambda, penaltyMat)
+		btb.Add(btb, &penalty)
 	}
 	btbSym := mat.NewSymDense(cols, btb.RawMatrix().Data)
-	r, c := btbSym.Dims()
-	fmt.Printf("BTB: %dx%d \n%0.2v\n", r, c, mat.Formatted(btbSym))
-
-	var eigsym mat.EigenSym
-	ok := eigsym.Factorize(btbSym, true)
-	if !ok {
-		fmt.Println("Eigendecomposition failed")
-	}
-	fmt.Printf("Eigenvalues of A:\n%f\n\n", eigsym.Values(nil))
 
 	var chol mat.Cholesky
 	if ok := chol.Factorize(btbSym); !ok {
-		panic(">>>>>>>>>>")
+		return nil, &splineerr.SingularSystemError{Cond: mat.Cond(btbSym, 1)}
 	}
-	L := mat.NewTriDense(cols, mat.Lower, nil)
-	chol.LTo(L)
-
-	L.InverseTri(L)
-	btb.Mul(L, regMat.T())
-	L.InverseTri(L)
-	btb.Mul(L, btb)
 
-	solver.bSolvedMat = btb
+	var solved mat.Dense
+	if err := chol.SolveTo(&solved, weighted.T()); err != nil {
+		if _, ok := err.(mat.Condition); !ok {
+			return nil, &splineerr.SingularSystemError{Cond: chol.Cond()}
+		}
+	}
+	return &solved, nil
 }
 
+// SolverMatrix Copy of the matrix S mapping observations to coefficients
 func (solver *SmoothSolver) SolverMatrix() *mat.Dense {
 	if solver.bSolvedMat == nil {
 		return nil
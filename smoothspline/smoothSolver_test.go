@@ -1,28 +1,586 @@
 package smoothspline
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"math/rand"
 	"testing"
 
 	"github.com/helloworldpark/gonaturalspline/bspline"
 	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/selection"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
 	"gonum.org/v1/gonum/mat"
 )
 
 func TestSmoothSolveRegressionMatrix(t *testing.T) {
 	const order = 3
-	knots := knot.NewUniformKnot(-10, 0, 11, order)
+	knots, err := knot.NewUniformKnot(-10, 0, 11, order)
+	if err != nil {
+		t.Fatal(err)
+	}
 	fmt.Println(knots, knots.Padding())
 
 	coef := make([]float64, knots.Count()+order)
-	simpleSpline := bspline.NewBSplineSimple(order, knots, coef)
+	simpleSpline, err := bspline.NewBSplineSimple(order, knots, coef)
+	if err != nil {
+		t.Fatal(err)
+	}
 
-	solver := NewSmoothSolver(simpleSpline, 0)
-	solver.calcRegressionMatrix()
+	x := make([]float64, 31)
+	y := make([]float64, len(x))
+	for i := range x {
+		x[i] = -10 + float64(i)/3
+		y[i] = math.Sin(x[i])
+	}
+
+	solver, err := NewSmoothSolver(simpleSpline, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := solver.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
 	solved := solver.RegressionMatrix()
 	fmt.Printf("B: %dx%d \n%0.2v\n", solved.RawMatrix().Rows, solved.RawMatrix().Cols, mat.Formatted(solved))
-	solver.calcCholesky()
 	solved = solver.SolverMatrix()
-	fmt.Printf("B: %dx%d \n%0.2v\n", solved.RawMatrix().Rows, solved.RawMatrix().Cols, mat.Formatted(solved))
+	fmt.Printf("S: %dx%d \n%0.2v\n", solved.RawMatrix().Rows, solved.RawMatrix().Cols, mat.Formatted(solved))
+}
+
+func TestSmoothSolverPenalty(t *testing.T) {
+	const order = 3
+	knots, err := knot.NewUniformKnot(0, 1, 6, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coef := make([]float64, knots.Count()+order)
+	simpleSpline, err := bspline.NewBSplineSimple(order, knots, coef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Coefficients reproducing f(x) = x^2, so integral of f''^2 over [0, 1] is 4
+	x := []float64{0, 0.1, 0.2, 0.3, 0.4, 0.5, 0.6, 0.7, 0.8, 0.9, 1}
+	y := make([]float64, len(x))
+	for i := range x {
+		y[i] = x[i] * x[i]
+	}
+	solver, err := NewSmoothSolver(simpleSpline, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := solver.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+
+	c := make([]float64, len(solver.basis))
+	for i, j := range solver.basis {
+		c[i] = simpleSpline.GetCoef(j - order)
+	}
+	C := mat.NewVecDense(len(c), c)
+	penalty := mat.Inner(C, solver.PenaltyMatrix(), C)
+	if math.Abs(penalty-4) > 1e-8 {
+		t.Fatalf("[SmoothSolver] Penalty of x^2 = %f, expected 4", penalty)
+	}
+}
+
+func TestSmoothSolverFit(t *testing.T) {
+	const order = 3
+	knots, err := knot.NewUniformKnot(0, 2*math.Pi, 20, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coef := make([]float64, knots.Count()+order)
+	simpleSpline, err := bspline.NewBSplineSimple(order, knots, coef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	x := make([]float64, 200)
+	y := make([]float64, len(x))
+	for i := range x {
+		x[i] = rnd.Float64() * 2 * math.Pi
+		y[i] = math.Sin(x[i]) + 0.1*rnd.NormFloat64()
+	}
+
+	solver, err := NewSmoothSolver(simpleSpline, 0.01)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := solver.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+	for x := 0.0; x <= 2*math.Pi; x += 0.1 {
+		if d := math.Abs(simpleSpline.At(x) - math.Sin(x)); d > 0.1 {
+			t.Fatalf("[SmoothSolver] |f(%f) - sin(%f)| = %f", x, x, d)
+		}
+	}
+
+	// Huge lambda shrinks the fit to the least squares line
+	solver, err = NewSmoothSolver(simpleSpline, 1e10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := solver.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+	slope := (simpleSpline.At(5) - simpleSpline.At(1)) / 4
+	for x := 0.0; x <= 2*math.Pi; x += 0.1 {
+		v := simpleSpline.At(1) + slope*(x-1)
+		if d := math.Abs(simpleSpline.At(x) - v); d > 1e-3 {
+			t.Fatalf("[SmoothSolver] Fit with huge lambda is not linear at %f: %f", x, d)
+		}
+	}
+}
+
+func TestSmoothSolverSelectLambda(t *testing.T) {
+	const order = 3
+	knots, err := knot.NewUniformKnot(0, 2*math.Pi, 30, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coef := make([]float64, knots.Count()+order)
+	simpleSpline, err := bspline.NewBSplineSimple(order, knots, coef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rnd := rand.New(rand.NewSource(2))
+	x := make([]float64, 300)
+	y := make([]float64, len(x))
+	for i := range x {
+		x[i] = rnd.Float64() * 2 * math.Pi
+		y[i] = math.Sin(x[i]) + 0.2*rnd.NormFloat64()
+	}
+
+	for _, criterion := range []selection.Criterion{selection.GCV, selection.LOOCV, selection.REML} {
+		solver, err := NewSmoothSolver(simpleSpline, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := solver.SelectLambda(x, y, criterion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if solver.Lambda() != result.Lambda {
+			t.Fatalf("[SmoothSolver] Lambda %f was not applied: %f", result.Lambda, solver.Lambda())
+		}
+		for x := 0.0; x <= 2*math.Pi; x += 0.1 {
+			if d := math.Abs(simpleSpline.At(x) - math.Sin(x)); d > 0.15 {
+				t.Fatalf("[SmoothSolver] |f(%f) - sin(%f)| = %f with lambda %g", x, x, d, result.Lambda)
+			}
+		}
+	}
+}
+
+func TestSmoothSolverSolveDF(t *testing.T) {
+	const order = 3
+	knots, err := knot.NewUniformKnot(0, 1, 15, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	coef := make([]float64, knots.Count()+order)
+	simpleSpline, err := bspline.NewBSplineSimple(order, knots, coef)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := make([]float64, 100)
+	y := make([]float64, len(x))
+	for i := range x {
+		x[i] = float64(i) / 99
+		y[i] = math.Exp(x[i])
+	}
+	solver, err := NewSmoothSolver(simpleSpline, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := solver.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+	lambda, err := solver.SolveDF(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var H mat.Dense
+	H.Mul(solver.RegressionMatrix(), solver.SolverMatrix())
+	if trace := mat.Trace(&H); math.Abs(trace-6) > 1e-4 {
+		t.Fatalf("[SmoothSolver] Trace of smoother matrix = %f at lambda %g, expected 6", trace, lambda)
+	}
+
+	// The ends of [2, number of basis functions] are the line and the unpenalized fit
+	_, dim := solver.RegressionMatrix().Dims()
+	for _, df := range []float64{2, float64(dim)} {
+		lambda, err := solver.SolveDF(df)
+		if err != nil {
+			t.Fatalf("[SmoothSolver] SolveDF(%f): %v", df, err)
+		}
+		H.Mul(solver.RegressionMatrix(), solver.SolverMatrix())
+		if trace := mat.Trace(&H); math.Abs(trace-df) > 1e-3 {
+			t.Fatalf("[SmoothSolver] Trace of smoother matrix = %f at lambda %g, expected %f", trace, lambda, df)
+		}
+	}
+}
+
+func TestSmoothSolverWeights(t *testing.T) {
+	const order = 3
+	knots, err := knot.NewUniformKnot(0, 1, 10, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x := make([]float64, 50)
+	y := make([]float64, len(x))
+	for i := range x {
+		x[i] = float64(i) / 49
+		y[i] = math.Cos(3 * x[i])
+	}
+	masked, err := bspline.NewBSplineSimple(order, knots, make([]float64, knots.Count()+order))
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver, err := NewSmoothSolver(masked, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Zero weight masks the outlier
+	w := make([]float64, len(x))
+	for i := range w {
+		w[i] = 1
+	}
+	w[20] = 0
+	y[20] = 100
+	if err := solver.SetWeights(w); err != nil {
+		t.Fatal(err)
+	}
+	if err := solver.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, err := bspline.NewBSplineSimple(order, knots, make([]float64, knots.Count()+order))
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver, err = NewSmoothSolver(deleted, 0.001)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := solver.Fit(append(append([]float64{}, x[:20]...), x[21:]...), append(append([]float64{}, y[:20]...), y[21:]...)); err != nil {
+		t.Fatal(err)
+	}
+
+	for v := 0.0; v <= 1; v += 0.01 {
+		if d := math.Abs(masked.At(v) - deleted.At(v)); d > 1e-8 {
+			t.Fatalf("[SmoothSolver] Masked and deleted fits differ at %f: %g", v, d)
+		}
+	}
+}
+
+func TestSmoothSolverErrors(t *testing.T) {
+	const order = 3
+	knots, err := knot.NewUniformKnot(0, 1, 10, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	simpleSpline, err := bspline.NewBSplineSimple(order, knots, make([]float64, knots.Count()+order))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSmoothSolver(simpleSpline, -1); !errors.Is(err, splineerr.ErrInvalidArgument) {
+		t.Fatalf("[SmoothSolver] Expected ErrInvalidArgument, got %v", err)
+	}
+	solver, err := NewSmoothSolver(simpleSpline, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := solver.SolveDF(4); !errors.Is(err, splineerr.ErrNotFitted) {
+		t.Fatalf("[SmoothSolver] Expected ErrNotFitted, got %v", err)
+	}
+	// Without smoothing, 3 observations cannot determine 12 coefficients
+	if err := solver.Fit([]float64{0.1, 0.5, 0.9}, []float64{1, 2, 3}); !errors.Is(err, splineerr.ErrSingularSystem) {
+		t.Fatalf("[SmoothSolver] Expected ErrSingularSystem, got %v", err)
+	}
+}
+
+func TestSmoothSolverPenaltyRank(t *testing.T) {
+	const order = 3
+	for m, null := range map[int]int{1: 2, order: 3, order + 1: 4} {
+		knots, err := knot.NewArbitraryKnotBuilder(order, 0, 0.2, 0.4, 0.8, 1).AppendWithMultiplicity(0.5, m).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		simpleSpline, err := bspline.NewBSplineSimple(order, knots, make([]float64, knots.Count()+order))
+		if err != nil {
+			t.Fatal(err)
+		}
+		x := make([]float64, 60)
+		y := make([]float64, len(x))
+		for i := range x {
+			x[i] = float64(i) / 59
+			y[i] = math.Abs(x[i] - 0.5)
+		}
+		solver, err := NewSmoothSolver(simpleSpline, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := solver.Fit(x, y); err != nil {
+			t.Fatal(err)
+		}
+
+		var eigen mat.EigenSym
+		if ok := eigen.Factorize(mat.NewSymDense(len(solver.basis), solver.PenaltyMatrix().RawMatrix().Data), false); !ok {
+			t.Fatal("[SmoothSolver] Eigendecomposition failed")
+		}
+		values := eigen.Values(nil)
+		var rank int
+		for _, v := range values {
+			if v > 1e-8*values[len(values)-1] {
+				rank++
+			}
+		}
+		if rank != solver.penaltyRank() || rank != len(solver.basis)-null {
+			t.Fatalf("[SmoothSolver] Multiplicity %d: rank %d, penaltyRank %d, expected %d", m, rank, solver.penaltyRank(), len(solver.basis)-null)
+		}
+	}
+}
+
+func TestSmoothSolverPeriodic(t *testing.T) {
+	const order = 3
+	knots, err := knot.NewPeriodicUniformKnot(0, 24, 13, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spline, err := bspline.NewPeriodicBSpline(order, knots, make([]float64, knots.Count()-1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Daily profile observed on hours of several days
+	rnd := rand.New(rand.NewSource(3))
+	x := make([]float64, 300)
+	y := make([]float64, len(x))
+	for i := range x {
+		x[i] = rnd.Float64() * 24
+		y[i] = math.Sin(2*math.Pi*x[i]/24) + 0.5*math.Cos(4*math.Pi*x[i]/24) + 0.1*rnd.NormFloat64()
+	}
+	solver, err := NewSmoothSolver(spline, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := solver.SelectLambda(x, y, selection.GCV); err != nil {
+		t.Fatal(err)
+	}
+	if r := solver.penaltyRank(); r != spline.CoefCount()-1 {
+		t.Fatalf("[SmoothSolver] Periodic penalty rank %d", r)
+	}
+	for v := 0.0; v < 24; v += 0.25 {
+		truth := math.Sin(2*math.Pi*v/24) + 0.5*math.Cos(4*math.Pi*v/24)
+		if d := math.Abs(spline.At(v) - truth); d > 0.1 {
+			t.Fatalf("[SmoothSolver] |f(%f) - truth| = %f", v, d)
+		}
+	}
+
+	// c^T * Omega * c against Simpson's rule, exact for the piecewise linear f''
+	c := make([]float64, spline.CoefCount())
+	for j := range c {
+		c[j] = spline.GetCoef(j - order)
+	}
+	C := mat.NewVecDense(len(c), c)
+	penalty := mat.Inner(C, solver.PenaltyMatrix(), C)
+	var numeric float64
+	for i := 0; i < knots.Count()-1; i++ {
+		a, b := knots.At(i), knots.At(i+1)
+		fa, fm, fb := spline.DerivativeAt(a, 2), spline.DerivativeAt((a+b)/2, 2), spline.DerivativeAt(b-1e-12, 2)
+		numeric += (b - a) / 6 * (fa*fa + 4*fm*fm + fb*fb)
+	}
+	if math.Abs(penalty-numeric) > 1e-6*numeric {
+		t.Fatalf("[SmoothSolver] Periodic penalty %f, numeric %f", penalty, numeric)
+	}
+	for k := 0; k < order; k++ {
+		left, right := spline.DerivativeAt(24-1e-9, k), spline.DerivativeAt(0, k)
+		if math.Abs(left-right) > 1e-5 {
+			t.Fatalf("[SmoothSolver] Derivative %d of the fit is %f before and %f after midnight", k, left, right)
+		}
+	}
+}
+
+func TestSmoothSolverQuantileKnots(t *testing.T) {
+	const order = 3
+	// Bursts of observations around 1, 4 and 9, none in between
+	rnd := rand.New(rand.NewSource(4))
+	x := make([]float64, 90)
+	y := make([]float64, len(x))
+	for i := range x {
+		x[i] = float64(1+i/30*(i/30+2)) + 0.3*rnd.Float64()
+		y[i] = math.Log(x[i])
+	}
+
+	uniform, err := knot.NewUniformKnot(1, 9.3, 12, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spline, err := bspline.NewBSplineSimple(order, uniform, make([]float64, uniform.Count()+order))
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver, err := NewSmoothSolver(spline, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := solver.Fit(x, y); !errors.Is(err, splineerr.ErrSingularSystem) {
+		t.Fatalf("[SmoothSolver] Expected ErrSingularSystem with empty spans, got %v", err)
+	}
+
+	quantile, err := knot.NewQuantileKnot(x, 9, order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spline, err = bspline.NewBSplineSimple(order, quantile, make([]float64, quantile.Count()+order))
+	if err != nil {
+		t.Fatal(err)
+	}
+	solver, err = NewSmoothSolver(spline, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := solver.Fit(x, y); err != nil {
+		t.Fatal(err)
+	}
+	for i := range x {
+		if d := math.Abs(spline.At(x[i]) - y[i]); d > 1e-3 {
+			t.Fatalf("[SmoothSolver] |f(%f) - log(%f)| = %f", x[i], x[i], d)
+		}
+	}
+}
+
+func TestSurfaceSolver(t *testing.T) {
+	knotsX, err := knot.NewClampedUniformKnot(0, math.Pi, 8, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	knotsY, err := knot.NewClampedUniformKnot(-1, 1, 6, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSurface := func() *bspline.Surface {
+		coefs := make([][]float64, knotsX.Count()+2)
+		for i := range coefs {
+			coefs[i] = make([]float64, knotsY.Count()+2)
+		}
+		surface, err := bspline.NewSurface(3, knotsX, 3, knotsY, coefs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return surface
+	}
+
+	// Sensor response against temperature and pressure
+	truth := func(x, y float64) float64 {
+		return math.Sin(x) * (1 + y*y)
+	}
+	rnd := rand.New(rand.NewSource(7))
+	n := 400
+	x, y, z := make([]float64, n), make([]float64, n), make([]float64, n)
+	for i := range x {
+		x[i] = rnd.Float64() * math.Pi
+		y[i] = 2*rnd.Float64() - 1
+		z[i] = truth(x[i], y[i]) + 0.05*rnd.NormFloat64()
+	}
+
+	surface := newSurface()
+	solver, err := NewSurfaceSolver(surface, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := solver.SelectLambda(x, y, z, selection.GCV)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("[SurfaceSolver] GCV lambda %g, df %f", result.Lambda, result.Fit.DF())
+	var sse float64
+	var count int
+	for u := 0.05; u < math.Pi; u += 0.1 {
+		for v := -0.95; v < 1; v += 0.1 {
+			d := surface.At(u, v) - truth(u, v)
+			sse += d * d
+			count++
+		}
+	}
+	if rmse := math.Sqrt(sse / float64(count)); rmse > 0.03 {
+		t.Fatalf("[SurfaceSolver] RMSE %f against the truth", rmse)
+	}
+
+	// The penalty does not penalize planes, so planes are reproduced at any lambda
+	nx, ny := knotsX.Count()+2, knotsY.Count()+2
+	if rank := solver.penaltyRank(); rank != nx*ny-3 {
+		t.Fatalf("[SurfaceSolver] Penalty of rank %d for %d basis functions", rank, nx*ny)
+	}
+	plane := make([]float64, n)
+	for i := range plane {
+		plane[i] = 1 + 2*x[i] - 3*y[i]
+	}
+	surface = newSurface()
+	solver, err = NewSurfaceSolver(surface, 1e6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := solver.Fit(x, y, plane); err != nil {
+		t.Fatal(err)
+	}
+	for u := 0.0; u <= math.Pi; u += 0.3 {
+		for v := -1.0; v <= 1; v += 0.25 {
+			if d := math.Abs(surface.At(u, v) - (1 + 2*u - 3*v)); d > 1e-6 {
+				t.Fatalf("[SurfaceSolver] Plane differs by %g at (%f, %f)", d, u, v)
+			}
+		}
+	}
+
+	// The penalty of a quadratic surface, fitted exactly, against its integral
+	quadratic := make([]float64, n)
+	for i := range quadratic {
+		quadratic[i] = x[i]*x[i] + x[i]*y[i]
+	}
+	surface = newSurface()
+	solver, err = NewSurfaceSolver(surface, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := solver.Fit(x, y, quadratic); err != nil {
+		t.Fatal(err)
+	}
+	coefs := make([]float64, nx*ny)
+	for a := 0; a < nx; a++ {
+		for b := 0; b < ny; b++ {
+			coefs[a*ny+b] = surface.GetCoef(a, b)
+		}
+	}
+	c := mat.NewVecDense(len(coefs), coefs)
+	// f_xx = 2, f_xy = 1, f_yy = 0 over the rectangle of area 2 pi
+	if penalty, integral := mat.Inner(c, solver.PenaltyMatrix(), c), (4+2)*2*math.Pi; math.Abs(penalty-integral) > 1e-6 {
+		t.Fatalf("[SurfaceSolver] Penalty %f, expected %f", penalty, integral)
+	}
 
+	if df, err := solver.SolveDF(10); err != nil || df <= 0 {
+		t.Fatalf("[SurfaceSolver] SolveDF: lambda %f, %v", df, err)
+	}
+	// The ends of [3, number of basis functions] are the plane and the unpenalized fit
+	for _, df := range []float64{3, float64(nx * ny)} {
+		if _, err := solver.SolveDF(df); err != nil {
+			t.Fatalf("[SurfaceSolver] SolveDF(%f): %v", df, err)
+		}
+		var H mat.Dense
+		H.Mul(solver.RegressionMatrix(), solver.bSolvedMat)
+		if trace := mat.Trace(&H); math.Abs(trace-df) > 1e-3 {
+			t.Fatalf("[SurfaceSolver] Trace of smoother matrix = %f, expected %f", trace, df)
+		}
+	}
+	if err := solver.Fit(x, y[:10], z); !errors.Is(err, splineerr.ErrDataLength) {
+		t.Fatalf("[SurfaceSolver] Expected ErrDataLength, got %v", err)
+	}
+	if _, err := NewSurfaceSolver(nil, 0); !errors.Is(err, splineerr.ErrInvalidArgument) {
+		t.Fatalf("[SurfaceSolver] Expected ErrInvalidArgument, got %v", err)
+	}
 }
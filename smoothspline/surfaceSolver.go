@@ -0,0 +1,284 @@
+package smoothspline
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/helloworldpark/gonaturalspline/bspline"
+	"github.com/helloworldpark/gonaturalspline/knot"
+	"github.com/helloworldpark/gonaturalspline/selection"
+	"github.com/helloworldpark/gonaturalspline/splineerr"
+	"gonum.org/v1/gonum/mat"
+)
+
+// SurfaceSolver Penalized tensor-product B-Spline smoothing of scattered observations
+// Minimizes
+//     sum_i w_i * (z_i - f(x_i, y_i))^2 + lambda * integral of (f_xx^2 + 2 * f_xy^2 + f_yy^2) dx dy
+// over the coefficients of the given bspline.Surface, the penalty being integrated over
+// [k_0, k_(count-1)] of the knots in x times that in y. The penalty is invariant to rotations
+// of the plane, and does not penalize planes, as that of SmoothSolver does not penalize lines.
+type SurfaceSolver struct {
+	surface        *bspline.Surface
+	bRegressionMat *mat.Dense
+	bSolvedMat     *mat.Dense
+	bPenaltyMat    *mat.Dense // scale by lambda at calculation
+	lambda         float64
+
+	// basisX, basisY Indices of the basis functions in x and y which are not identically zero
+	// on [k_0, k_(count-1)]. The coefficient c_(basisX[a], basisY[b]) is fitted at a * len(basisY) + b.
+	basisX, basisY []int
+	// x, y, z Observations of the last fit
+	x, y, z []float64
+	// w Weights of the observations. If nil, all weights are 1.
+	w []float64
+}
+
+// NewSurfaceSolver A new pointer of SurfaceSolver fitting the coefficients of surface
+func NewSurfaceSolver(surface *bspline.Surface, lambda float64) (*SurfaceSolver, error) {
+	if surface == nil {
+		return nil, fmt.Errorf("[SurfaceSolver] No surface was given: %w", splineerr.ErrInvalidArgument)
+	}
+	if lambda < 0 || math.IsNaN(lambda) {
+		return nil, fmt.Errorf("[SurfaceSolver] Lambda %f: %w", lambda, splineerr.ErrInvalidArgument)
+	}
+	return &SurfaceSolver{
+		surface: surface,
+		lambda:  lambda,
+	}, nil
+}
+
+// SetWeights Set the weights w_i of the observations of the following fits.
+// A weight of 0 masks the observation. If nil, all weights are 1.
+func (solver *SurfaceSolver) SetWeights(w []float64) error {
+	for _, v := range w {
+		if v < 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("[SurfaceSolver] Weight %f: %w", v, splineerr.ErrInvalidWeights)
+		}
+	}
+	solver.w = w
+	return nil
+}
+
+// Fit Fit the surface to the observations (x_i, y_i, z_i).
+// The coefficients are written back to the surface.
+// Coefficients of basis functions vanishing on the rectangle of the knots are set to 0.
+func (solver *SurfaceSolver) Fit(x, y, z []float64) error {
+	if err := solver.prepare(x, y, z); err != nil {
+		return err
+	}
+	solved, err := solvePenalized(solver.bRegressionMat, solver.bPenaltyMat, solver.lambda, solver.w)
+	if err != nil {
+		return err
+	}
+	solver.bSolvedMat = solved
+	solver.x, solver.y, solver.z = x, y, z
+
+	Z := mat.NewVecDense(len(z), z)
+	var coefs mat.VecDense
+	coefs.MulVec(solver.bSolvedMat, Z)
+
+	nx, ny := solver.surface.CoefCount()
+	for i := 0; i < nx; i++ {
+		for j := 0; j < ny; j++ {
+			solver.surface.SetCoef(i, j, 0)
+		}
+	}
+	for a, i := range solver.basisX {
+		for b, j := range solver.basisY {
+			solver.surface.SetCoef(i, j, coefs.AtVec(a*len(solver.basisY)+b))
+		}
+	}
+	return nil
+}
+
+// SelectLambda Choose lambda minimizing the criterion, then fit the surface with it
+func (solver *SurfaceSolver) SelectLambda(x, y, z []float64, criterion selection.Criterion) (selection.Result, error) {
+	if err := solver.prepare(x, y, z); err != nil {
+		return selection.Result{}, err
+	}
+	pls := selection.NewPenalizedLeastSquares(solver.bRegressionMat, solver.bPenaltyMat, solver.penaltyRank(), z, solver.w)
+	result, err := selection.Minimize(pls, criterion)
+	if err != nil {
+		return selection.Result{}, err
+	}
+	solver.lambda = result.Lambda
+	return result, solver.Fit(x, y, z)
+}
+
+// SolveDF Refit the observations of the last fit with lambda whose smoother matrix has trace df,
+// and return the lambda. df should be in [3, number of basis functions].
+func (solver *SurfaceSolver) SolveDF(df float64) (float64, error) {
+	if solver.x == nil {
+		return 0, fmt.Errorf("[SurfaceSolver] SolveDF needs observations, call Fit first: %w", splineerr.ErrNotFitted)
+	}
+	if err := solver.prepare(solver.x, solver.y, solver.z); err != nil {
+		return 0, err
+	}
+	pls := selection.NewPenalizedLeastSquares(solver.bRegressionMat, solver.bPenaltyMat, solver.penaltyRank(), nil, solver.w)
+	lambda, _, err := selection.LambdaForDF(pls, df)
+	if err != nil {
+		return 0, err
+	}
+	solver.lambda = lambda
+	return lambda, solver.Fit(solver.x, solver.y, solver.z)
+}
+
+// Lambda Smoothing parameter
+func (solver *SurfaceSolver) Lambda() float64 {
+	return solver.lambda
+}
+
+// prepare Validate the observations and build the regression and penalty matrices
+func (solver *SurfaceSolver) prepare(x, y, z []float64) error {
+	if len(x) != len(z) || len(y) != len(z) {
+		return fmt.Errorf("[SurfaceSolver] %d and %d abscissae, %d observations: %w", len(x), len(y), len(z), splineerr.ErrDataLength)
+	}
+	if solver.w != nil && len(solver.w) != len(z) {
+		return fmt.Errorf("[SurfaceSolver] %d observations, %d weights: %w", len(z), len(solver.w), splineerr.ErrDataLength)
+	}
+	splineX, splineY, err := solver.marginals()
+	if err != nil {
+		return err
+	}
+	solver.basisX = supportedBasis(splineX)
+	solver.basisY = supportedBasis(splineY)
+	solver.calcRegressionMatrix(splineX, splineY, x, y)
+	return solver.calcPenaltyMatrix()
+}
+
+// marginals B-Splines in x and y with the basis functions of the surface
+func (solver *SurfaceSolver) marginals() (bspline.BSpline, bspline.BSpline, error) {
+	orderX, orderY := solver.surface.Orders()
+	knotsX, knotsY := solver.surface.Knots()
+	nx, ny := solver.surface.CoefCount()
+	splineX, err := bspline.NewBSplineSimple(orderX, knotsX, make([]float64, nx))
+	if err != nil {
+		return nil, nil, err
+	}
+	splineY, err := bspline.NewBSplineSimple(orderY, knotsY, make([]float64, ny))
+	if err != nil {
+		return nil, nil, err
+	}
+	return splineX, splineY, nil
+}
+
+// calcRegressionMatrix B_(i, a * len(basisY) + b) = B_(basisX[a])(x_i) * C_(basisY[b])(y_i)
+func (solver *SurfaceSolver) calcRegressionMatrix(splineX, splineY bspline.BSpline, x, y []float64) {
+	columnX := columns(solver.basisX)
+	columnY := columns(solver.basisY)
+	B := mat.NewDense(len(x), len(solver.basisX)*len(solver.basisY), nil)
+	for i := range x {
+		firstX, valuesX := splineX.NonzeroBSplines(x[i])
+		firstY, valuesY := splineY.NonzeroBSplines(y[i])
+		for m, u := range valuesX {
+			a, ok := columnX[firstX+m]
+			if !ok {
+				continue
+			}
+			for l, v := range valuesY {
+				if b, ok := columnY[firstY+l]; ok {
+					B.Set(i, a*len(solver.basisY)+b, u*v)
+				}
+			}
+		}
+	}
+	solver.bRegressionMat = B
+}
+
+// columns Position of each basis function among basis
+func columns(basis []int) map[int]int {
+	column := make(map[int]int, len(basis))
+	for c, j := range basis {
+		column[j] = c
+	}
+	return column
+}
+
+// calcPenaltyMatrix Penalty of the tensor-product basis from the Gram matrices G^(d)
+// of the d-th derivatives of the bases in x and y:
+//     Omega = G_x^(2) (x) G_y^(0) + 2 * G_x^(1) (x) G_y^(1) + G_x^(0) (x) G_y^(2)
+// where (x) is the Kronecker product, ordered as the columns of the regression matrix.
+func (solver *SurfaceSolver) calcPenaltyMatrix() error {
+	orderX, orderY := solver.surface.Orders()
+	knotsX, knotsY := solver.surface.Knots()
+	gramX, err := derivativeGramMatrices(knotsX, orderX, solver.basisX)
+	if err != nil {
+		return err
+	}
+	gramY, err := derivativeGramMatrices(knotsY, orderY, solver.basisY)
+	if err != nil {
+		return err
+	}
+
+	nx, ny := len(solver.basisX), len(solver.basisY)
+	P := mat.NewDense(nx*ny, nx*ny, nil)
+	for a := 0; a < nx; a++ {
+		for c := 0; c < nx; c++ {
+			for b := 0; b < ny; b++ {
+				for d := 0; d < ny; d++ {
+					v := gramX[2].At(a, c)*gramY[0].At(b, d) +
+						2*gramX[1].At(a, c)*gramY[1].At(b, d) +
+						gramX[0].At(a, c)*gramY[2].At(b, d)
+					P.Set(a*ny+b, c*ny+d, v)
+				}
+			}
+		}
+	}
+	solver.bPenaltyMat = P
+	return nil
+}
+
+// derivativeGramMatrices Gram matrices of the basis functions and their first and second derivatives,
+// restricted to basis
+func derivativeGramMatrices(knots knot.Knot, order int, basis []int) ([3]*mat.Dense, error) {
+	var grams [3]*mat.Dense
+	for deriv := range grams {
+		full, err := derivativeGramMatrix(knots, order, deriv)
+		if err != nil {
+			return grams, err
+		}
+		G := mat.NewDense(len(basis), len(basis), nil)
+		for r, j := range basis {
+			for c, k := range basis {
+				G.Set(r, c, full.At(j, k))
+			}
+		}
+		grams[deriv] = G
+	}
+	return grams, nil
+}
+
+// penaltyRank Rank of the penalty matrix, counted from its eigenvalues.
+// Planes are not penalized, but knots of multiplicity order or more allow more of the null space.
+func (solver *SurfaceSolver) penaltyRank() int {
+	n, _ := solver.bPenaltyMat.Dims()
+	var eigen mat.EigenSym
+	if ok := eigen.Factorize(mat.NewSymDense(n, solver.bPenaltyMat.RawMatrix().Data), false); !ok {
+		return 0
+	}
+	values := eigen.Values(nil)
+	largest := values[len(values)-1]
+	var rank int
+	for _, v := range values {
+		if v > 1e-10*largest {
+			rank++
+		}
+	}
+	return rank
+}
+
+// RegressionMatrix Copy of the matrix of the basis functions at the observations of the last fit
+func (solver *SurfaceSolver) RegressionMatrix() *mat.Dense {
+	if solver.bRegressionMat == nil {
+		return nil
+	}
+	return mat.DenseCopyOf(solver.bRegressionMat)
+}
+
+// PenaltyMatrix Copy of the penalty matrix of the last fit, not scaled by lambda
+func (solver *SurfaceSolver) PenaltyMatrix() *mat.Dense {
+	if solver.bPenaltyMat == nil {
+		return nil
+	}
+	return mat.DenseCopyOf(solver.bPenaltyMat)
+}
@@ -0,0 +1,43 @@
+// Package splineerr Errors returned by the constructors and solvers of gonaturalspline.
+// Errors are wrapped with context, so compare them with errors.Is.
+package splineerr
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrInvalidKnots Knots are empty, unsorted or otherwise unusable
+	ErrInvalidKnots = errors.New("invalid knots")
+	// ErrInvalidOrder Order of a spline is out of range
+	ErrInvalidOrder = errors.New("invalid order")
+	// ErrCoefLength Length of the coefficients does not match the basis
+	ErrCoefLength = errors.New("length of coefficients does not match the basis")
+	// ErrDataLength Lengths of observations, abscissae or weights do not match
+	ErrDataLength = errors.New("lengths of observations do not match")
+	// ErrInvalidWeights Weights are negative or not finite
+	ErrInvalidWeights = errors.New("invalid weights")
+	// ErrInvalidArgument Argument is out of its valid range
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrNotFitted Solver was used before fitting any observation
+	ErrNotFitted = errors.New("not fitted")
+	// ErrSingularSystem Linear system is singular or not positive definite.
+	// Returned as *SingularSystemError.
+	ErrSingularSystem = errors.New("singular system")
+)
+
+// SingularSystemError Linear system could not be solved
+type SingularSystemError struct {
+	// Cond Estimate of the condition number of the system
+	Cond float64
+}
+
+func (e *SingularSystemError) Error() string {
+	return fmt.Sprintf("%v: condition number %g", ErrSingularSystem, e.Cond)
+}
+
+// Is Matches ErrSingularSystem
+func (e *SingularSystemError) Is(target error) bool {
+	return target == ErrSingularSystem
+}